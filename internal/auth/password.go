@@ -1,27 +1,65 @@
-// Package auth handles password prompts and authentication
+// Package auth handles password prompts and authentication.
+// Prompts are written to stderr so they never mix with command results on stdout.
 package auth
 
 import (
 	"bufio"
 	"fmt"
 	"os"
+	"runtime"
 	"strings"
-	"syscall"
 
 	"golang.org/x/term"
 )
 
-func PromptPassword(prompt string) (string, error) {
-	fmt.Print(prompt)
+// terminalInput returns the file prompts should read from. When stdin is
+// redirected (for example a secret piped into 'envy set --stdin') prompts
+// fall back to the controlling terminal so the pipe is left untouched.
+// The returned cleanup function closes the terminal if one was opened.
+func terminalInput() (*os.File, func()) {
+	name := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		name = "CONIN$"
+	}
+	return PromptInput(os.Stdin, name)
+}
+
+// PromptInput picks between stdin and the terminal device tty: stdin when it
+// is a terminal, otherwise tty, or stdin again if tty can't be opened
+func PromptInput(stdin *os.File, tty string) (*os.File, func()) {
+	if term.IsTerminal(int(stdin.Fd())) {
+		return stdin, func() {}
+	}
+
+	f, err := os.OpenFile(tty, os.O_RDWR, 0)
+	if err != nil {
+		return stdin, func() {}
+	}
+	return f, func() { f.Close() }
+}
+
+func readHidden(prompt string) (string, error) {
+	input, cleanup := terminalInput()
+	defer cleanup()
+
+	fmt.Fprint(os.Stderr, prompt)
 
-	bytePassword, err := term.ReadPassword(int(syscall.Stdin))
-	fmt.Println()
+	bytePassword, err := term.ReadPassword(int(input.Fd()))
+	fmt.Fprintln(os.Stderr)
 
+	if err != nil {
+		return "", err
+	}
+	return string(bytePassword), nil
+}
+
+func PromptPassword(prompt string) (string, error) {
+	bytePassword, err := readHidden(prompt)
 	if err != nil {
 		return "", fmt.Errorf("failed to read password: %w", err)
 	}
 
-	password := strings.TrimSpace(string(bytePassword))
+	password := strings.TrimSpace(bytePassword)
 	if password == "" {
 		return "", fmt.Errorf("password cannot be empty")
 	}
@@ -29,6 +67,16 @@ func PromptPassword(prompt string) (string, error) {
 	return password, nil
 }
 
+// PromptSecret reads a secret value without echoing it. Unlike PromptPassword
+// the value is returned untrimmed.
+func PromptSecret(prompt string) (string, error) {
+	value, err := readHidden(prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret: %w", err)
+	}
+	return value, nil
+}
+
 func PromptNewPassword() (string, error) {
 	password, err := PromptPassword("Create master password: ")
 	if err != nil {
@@ -52,8 +100,11 @@ func PromptNewPassword() (string, error) {
 }
 
 func PromptText(prompt string) (string, error) {
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Print(prompt)
+	input, cleanup := terminalInput()
+	defer cleanup()
+
+	reader := bufio.NewReader(input)
+	fmt.Fprint(os.Stderr, prompt)
 	text, err := reader.ReadString('\n')
 	if err != nil {
 		return "", fmt.Errorf("failed to read input: %w", err)
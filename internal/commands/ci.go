@@ -0,0 +1,238 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"envy/internal/domain"
+	"envy/internal/service"
+	"envy/internal/storage"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// masterPasswordEnv holds the master password in CI, where there is no
+// terminal to prompt on
+const masterPasswordEnv = "ENVY_MASTER_PASSWORD"
+
+type ciExportResult struct {
+	Project     string   `json:"project"`
+	Environment string   `json:"environment"`
+	Provider    string   `json:"provider"`
+	Path        string   `json:"path"`
+	Keys        int      `json:"keys"`
+	Masked      int      `json:"masked"`
+	Unmasked    []string `json:"unmasked,omitempty"`
+}
+
+var ciCmd = &cobra.Command{
+	Use:   "ci",
+	Short: "Load secrets into CI jobs",
+	Long: `Load secrets into CI jobs without writing them to the job log.
+
+The master password is read from $ENVY_MASTER_PASSWORD when it is set.
+
+Examples:
+  envy ci export myapp -e prod
+  envy ci export myapp -e prod --provider gitlab -o "$CI_PROJECT_DIR/.envy.sh"`,
+}
+
+var ciExportCmd = &cobra.Command{
+	Use:   "export <project[:env]> [-e env] [--provider github|gitlab|generic]",
+	Short: "Export a project to the environment of later CI steps",
+	Long: `Export the current values of a project for a CI provider, detected from
+the variables CI systems set unless --provider is given.
+
+github   Every value is masked with ::add-mask:: before anything else is
+         written, then the variables are appended to $GITHUB_ENV for the
+         following steps. Multiline values are written as heredocs.
+gitlab,  No masking. GitLab only masks variables defined in the project's
+generic  CI/CD settings and other systems can't mask at all, so a value a
+         later command prints shows in the job log as it is; a warning
+         names each such key. The variables are written with -o to a file
+         created with mode 0600: shell exports to source, or with
+         --format dotenv KEY=VALUE lines for a GitLab
+         artifacts:reports:dotenv file.
+
+In a CI job (CI or GITLAB_CI set) the variables are only printed to stdout
+with --stdout, since a stray echo or set -x would put them in the log.
+
+Examples:
+  envy ci export myapp -e prod
+  envy ci export myapp -e prod --provider gitlab -o "$CI_PROJECT_DIR/.envy.sh"
+  envy ci export myapp:prod --provider gitlab --format dotenv -o deploy.env
+  eval "$(envy ci export myapp:prod --provider generic --stdout)"`,
+	Args:              usageArgs(cobra.ExactArgs(1)),
+	RunE:              runCIExportCommand,
+	ValidArgsFunction: completeProjectArg,
+}
+
+func init() {
+	RootCmd.AddCommand(ciCmd)
+	ciCmd.AddCommand(ciExportCmd)
+	ciExportCmd.Flags().StringP("env", "e", "", "Environment of the project (dev, stage, prod)")
+	ciExportCmd.Flags().String("provider", "", "CI provider: github, gitlab or generic (default detected)")
+	ciExportCmd.Flags().StringP("out", "o", "", "Write the variables to this file (default $GITHUB_ENV for github)")
+	ciExportCmd.Flags().Bool("stdout", false, "Print the variables to stdout for eval, also in a CI job (gitlab and generic)")
+	ciExportCmd.Flags().String("format", "", "Format for gitlab and generic: shell or dotenv (default shell)")
+	ciExportCmd.Flags().Bool("raw", false, "Export stored values without expanding references")
+	ciExportCmd.RegisterFlagCompletionFunc("env", completeEnvironments)
+	ciExportCmd.RegisterFlagCompletionFunc("provider", cobra.FixedCompletions(service.CIProviders, cobra.ShellCompDirectiveNoFileComp))
+	ciExportCmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(service.CIFormats, cobra.ShellCompDirectiveNoFileComp))
+}
+
+func runCIExportCommand(cmd *cobra.Command, args []string) error {
+	projectName, environment := service.ParseProjectSpec(args[0])
+	if environment == "" {
+		environment, _ = cmd.Flags().GetString("env")
+	}
+	provider, _ := cmd.Flags().GetString("provider")
+	out, _ := cmd.Flags().GetString("out")
+	toStdout, _ := cmd.Flags().GetBool("stdout")
+	format, _ := cmd.Flags().GetString("format")
+	raw, _ := cmd.Flags().GetBool("raw")
+
+	if provider == "" {
+		provider = service.DetectCIProvider(os.Getenv)
+	}
+	provider = strings.ToLower(provider)
+	if !slices.Contains(service.CIProviders, provider) {
+		return usageErrorf("invalid --provider '%s' (must be one of %s)", provider, strings.Join(service.CIProviders, ", "))
+	}
+	if format != "" && !slices.Contains(service.CIFormats, format) {
+		return usageErrorf("invalid --format '%s' (must be one of %s)", format, strings.Join(service.CIFormats, ", "))
+	}
+	if out == "-" {
+		return usageErrorf("use --stdout to print the variables")
+	}
+	if toStdout && out != "" {
+		return usageErrorf("--stdout and -o can't be used together")
+	}
+
+	if provider == "github" {
+		if toStdout || format != "" {
+			return usageErrorf("the github provider always writes $GITHUB_ENV; --stdout and --format are for gitlab and generic")
+		}
+		if out == "" {
+			if out = os.Getenv("GITHUB_ENV"); out == "" {
+				return usageErrorf("$GITHUB_ENV isn't set; run in a GitHub Actions step or use -o")
+			}
+		}
+	} else if out == "" && !toStdout {
+		// Outside CI printing stays the default, for eval in a local shell
+		if service.InCI(os.Getenv) {
+			return usageErrorf("not printing plain-text secrets in a CI job; use -o <file>, or --stdout to print them anyway")
+		}
+		toStdout = true
+	}
+	if toStdout && term.IsTerminal(int(os.Stdout.Fd())) {
+		return usageErrorf("not printing plain-text secrets to the terminal; use -o or eval \"$(envy ci export ...)\"")
+	}
+
+	projects, err := unlockVaultFromEnvironment()
+	if err != nil {
+		return err
+	}
+	project, err := resolveProject(projects, projectName, environment)
+	if err != nil {
+		return err
+	}
+	if !raw {
+		resolver := service.NewResolver(projects)
+		resolved, err := resolver.Project(*project)
+		if err != nil {
+			return withContext(err, project.Name, project.Environment, "")
+		}
+		warnUnresolved(resolver)
+		project = &resolved
+	}
+
+	export, err := service.FormatCIExport(provider, format, *project)
+	if err != nil {
+		return withContext(err, project.Name, project.Environment, "")
+	}
+
+	// Masks go out first, so the runner knows every value before any step
+	// can print one
+	if _, err := os.Stdout.Write(export.Log); err != nil {
+		return err
+	}
+
+	result := ciExportResult{
+		Project:     project.Name,
+		Environment: project.Environment,
+		Provider:    provider,
+		Path:        out,
+		Keys:        len(project.Keys),
+		Masked:      export.Masked,
+		Unmasked:    export.Unmasked,
+	}
+	for _, key := range export.Unmasked {
+		infof("Warning: %s can't be masked by %s; if a step prints it, it shows in the job log\n", key, provider)
+	}
+
+	if toStdout {
+		if _, err := os.Stdout.Write(export.Env); err != nil {
+			return err
+		}
+		infof("Exported %d keys from '%s' (%s) for %s.\n", result.Keys, project.Name, project.Environment, provider)
+		return nil
+	}
+
+	if provider == "github" {
+		err = appendPrivateFile(out, export.Env)
+	} else {
+		err = writePrivateFile(out, export.Env)
+	}
+	if err != nil {
+		return withContext(err, project.Name, project.Environment, "")
+	}
+
+	printResult(result, func() {
+		infof("Exported %d keys from '%s' (%s) to %s", result.Keys, project.Name, project.Environment, out)
+		if provider == "github" {
+			infof(", %d values masked", result.Masked)
+		}
+		infof(".\n")
+	})
+	return nil
+}
+
+// unlockVaultFromEnvironment unlocks the vault with $ENVY_MASTER_PASSWORD,
+// or prompts for the master password when it isn't set
+func unlockVaultFromEnvironment() ([]domain.Project, error) {
+	password := os.Getenv(masterPasswordEnv)
+	if password == "" {
+		projects, _, err := unlockVault()
+		return projects, err
+	}
+
+	firstRun, err := storage.IsFirstRun()
+	if err != nil {
+		return nil, vaultError("failed to check vault status", err)
+	}
+	if firstRun {
+		return nil, errNoVault
+	}
+	projects, _, err := storage.Load(password)
+	if err != nil {
+		return nil, vaultError("failed to load vault", err)
+	}
+	return projects, nil
+}
+
+// appendPrivateFile appends data to path, creating it with mode 0600
+func appendPrivateFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"os"
+	"slices"
+	"strings"
+
+	"envy/internal/domain"
+	"envy/internal/service"
+	"envy/internal/storage"
+
+	"github.com/spf13/cobra"
+)
+
+/*
+	Shell completion reads project and key names straight from the vault file.
+	Names are stored in plaintext next to the encrypted values, so no password
+	is needed and no secret is ever decrypted. When the vault is missing or
+	unreadable completion silently offers nothing.
+
+	Scripts are generated by cobra's built-in command:
+	  envy completion bash|zsh|fish|powershell
+*/
+
+// completionProjects loads vault metadata, returning nil when it isn't available
+func completionProjects() []domain.Project {
+	projects, err := storage.LoadMetadata()
+	if err != nil {
+		return nil
+	}
+	return projects
+}
+
+// envFlag returns the value of the command's --env flag, or "" when it has none
+func envFlag(cmd *cobra.Command) string {
+	if cmd.Flags().Lookup("env") == nil {
+		return ""
+	}
+	env, _ := cmd.Flags().GetString("env")
+	return env
+}
+
+func completeProjectFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return service.ProjectNameCandidates(completionProjects(), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeEnvironments(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{domain.EnvDev, domain.EnvStage, domain.EnvProd}, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProjectArg completes a single project argument
+func completeProjectArg(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return service.ProjectNameCandidates(completionProjects(), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeRunArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	// After '--' the shell's own completion applies to the child command
+	if completingAfterDash() {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	// Every argument before it is a project[:env] layer
+	projects := completionProjects()
+	candidates := service.ProjectNameCandidates(projects, toComplete)
+	candidates = append(candidates, service.ProjectSpecCandidates(projects, toComplete)...)
+	return candidates, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completingAfterDash reports whether a '--' comes before the word being
+// completed. Cobra parses the words with an extra '--' appended before asking
+// for completions, so ArgsLenAtDash can't tell; the words are still in os.Args.
+func completingAfterDash() bool {
+	words := os.Args[1:]
+	return len(words) > 0 && slices.Contains(words[:len(words)-1], "--")
+}
+
+func completeSetArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return service.ProjectNameCandidates(completionProjects(), toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+	if strings.Contains(toComplete, "=") {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	keys := service.KeyNameCandidates(completionProjects(), args[0], envFlag(cmd), toComplete)
+	for i := range keys {
+		keys[i] += "="
+	}
+	return keys, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+func completeProjectThenKey(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		return service.ProjectNameCandidates(completionProjects(), toComplete), cobra.ShellCompDirectiveNoFileComp
+	case 1:
+		name, env := service.ParseProjectSpec(args[0])
+		if env == "" {
+			env = envFlag(cmd)
+		}
+		return service.KeyNameCandidates(completionProjects(), name, env, toComplete), cobra.ShellCompDirectiveNoFileComp
+	default:
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func completeDiffArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	// Environments after 'envy diff <project>'
+	if len(args) > 0 {
+		if _, env := service.ParseProjectSpec(args[0]); env == "" {
+			return []string{domain.EnvDev, domain.EnvStage, domain.EnvProd}, cobra.ShellCompDirectiveNoFileComp
+		}
+	}
+
+	return service.ProjectSpecCandidates(completionProjects(), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,247 @@
+package commands
+
+import (
+	"fmt"
+
+	"envy/internal/crypto"
+	"envy/internal/domain"
+	"envy/internal/service"
+	"envy/internal/storage"
+
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [project] [env] [env] | [project:env] [project:env]",
+	Short: "Compare keys between environments, projects or a backup",
+	Long: `Compare the secrets of two projects and report drift.
+
+Keys missing on either side and keys whose values differ are listed.
+Values are never printed: each one is shown as a salted fingerprint and
+its length, so equal values have equal fingerprints within a single run.
+
+Examples:
+  envy diff myapp dev prod
+  envy diff api:dev worker:dev
+  envy diff myapp:prod --backup ~/.envy/keys.json.backup
+
+Use --reveal to print plaintext values (asks for confirmation) and
+--exit-code to exit with status 10 when differences are found.`,
+	Args:              usageArgs(cobra.RangeArgs(1, 3)),
+	RunE:              runDiffCommand,
+	ValidArgsFunction: completeDiffArgs,
+}
+
+func init() {
+	RootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().Bool("reveal", false, "Show plaintext values (asks for confirmation)")
+	diffCmd.Flags().Bool("exit-code", false, "Exit with status 10 when differences are found")
+	diffCmd.Flags().String("backup", "", "Compare a project against the same project in a backup vault file")
+}
+
+type diffSide struct {
+	name string
+	env  string
+}
+
+func (s diffSide) String() string {
+	return fmt.Sprintf("%s (%s)", s.name, s.env)
+}
+
+func runDiffCommand(cmd *cobra.Command, args []string) error {
+	reveal, _ := cmd.Flags().GetBool("reveal")
+	exitCode, _ := cmd.Flags().GetBool("exit-code")
+	backupPath, _ := cmd.Flags().GetString("backup")
+
+	left, right, err := parseDiffArgs(args, backupPath != "")
+	if err != nil {
+		return err
+	}
+
+	if reveal {
+		ok, err := confirm("Reveal plaintext secret values on screen? [y/N]: ")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			reveal = false
+		}
+	}
+
+	password, err := promptUnlockPassword()
+	if err != nil {
+		return err
+	}
+
+	projects, _, err := storage.Load(password)
+	if err != nil {
+		return vaultError("failed to load vault", err)
+	}
+
+	leftProject, err := findProject(projects, left.name, left.env)
+	if err != nil {
+		return err
+	}
+
+	rightProjects := projects
+	if backupPath != "" {
+		rightProjects, _, err = storage.LoadFile(backupPath, password)
+		if err != nil {
+			return vaultError("failed to load backup", err)
+		}
+	}
+
+	rightProject, err := findProject(rightProjects, right.name, right.env)
+	if err != nil {
+		if backupPath != "" {
+			return withContext(fmt.Errorf("%w in backup", err), right.name, right.env, "")
+		}
+		return err
+	}
+
+	leftLabel := diffSide{leftProject.Name, leftProject.Environment}.String()
+	rightLabel := diffSide{rightProject.Name, rightProject.Environment}.String()
+	if backupPath != "" {
+		rightLabel += " [backup]"
+	}
+
+	salt, err := crypto.GenerateSalt()
+	if err != nil {
+		return err
+	}
+
+	diffs := service.DiffProjects(*leftProject, *rightProject)
+	printResult(newDiffResult(leftProject, rightProject, diffs, reveal, salt), func() {
+		printDiff(diffs, leftLabel, rightLabel, reveal, salt)
+	})
+
+	if exitCode && service.HasDrift(diffs) {
+		return &CommandError{Code: ExitDrift, Err: fmt.Errorf("%s and %s differ", leftLabel, rightLabel), quiet: true}
+	}
+	return nil
+}
+
+func parseDiffArgs(args []string, withBackup bool) (diffSide, diffSide, error) {
+	if withBackup {
+		if len(args) != 1 {
+			return diffSide{}, diffSide{}, usageErrorf("--backup expects a single project:env argument")
+		}
+		name, env := service.ParseProjectSpec(args[0])
+		if env == "" {
+			env = domain.EnvDev
+		}
+		side := diffSide{name, env}
+		return side, side, nil
+	}
+
+	switch len(args) {
+	case 3:
+		for _, env := range args[1:] {
+			if err := domain.ValidateEnvironment(env); err != nil {
+				return diffSide{}, diffSide{}, fmt.Errorf("invalid environment: %w", err)
+			}
+		}
+		return diffSide{args[0], args[1]}, diffSide{args[0], args[2]}, nil
+	case 2:
+		leftName, leftEnv := service.ParseProjectSpec(args[0])
+		rightName, rightEnv := service.ParseProjectSpec(args[1])
+		if leftEnv == "" || rightEnv == "" {
+			return diffSide{}, diffSide{}, usageErrorf("expected project:env arguments, e.g. 'envy diff api:dev worker:dev'")
+		}
+		return diffSide{leftName, leftEnv}, diffSide{rightName, rightEnv}, nil
+	default:
+		return diffSide{}, diffSide{}, usageErrorf("expected 'envy diff <project> <env> <env>' or 'envy diff <project:env> <project:env>'")
+	}
+}
+
+type diffValue struct {
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Length      int    `json:"length"`
+	Value       string `json:"value,omitempty"`
+}
+
+type diffEntry struct {
+	Key    string     `json:"key"`
+	Status string     `json:"status"`
+	Left   *diffValue `json:"left,omitempty"`
+	Right  *diffValue `json:"right,omitempty"`
+}
+
+type diffResult struct {
+	Left        diffProject `json:"left"`
+	Right       diffProject `json:"right"`
+	Drift       bool        `json:"drift"`
+	Differences []diffEntry `json:"differences"`
+}
+
+type diffProject struct {
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+}
+
+func newDiffResult(left, right *domain.Project, diffs []service.KeyDiff, reveal bool, salt []byte) diffResult {
+	describe := func(value string) *diffValue {
+		v := &diffValue{Length: len(value)}
+		if reveal {
+			v.Value = value
+		} else {
+			v.Fingerprint = service.Fingerprint(value, salt)
+		}
+		return v
+	}
+
+	result := diffResult{
+		Left:        diffProject{left.Name, left.Environment},
+		Right:       diffProject{right.Name, right.Environment},
+		Drift:       service.HasDrift(diffs),
+		Differences: []diffEntry{},
+	}
+
+	for _, d := range diffs {
+		switch d.Kind {
+		case service.DiffOnlyLeft:
+			result.Differences = append(result.Differences, diffEntry{Key: d.Key, Status: "missing_right", Left: describe(d.Left)})
+		case service.DiffOnlyRight:
+			result.Differences = append(result.Differences, diffEntry{Key: d.Key, Status: "missing_left", Right: describe(d.Right)})
+		case service.DiffChanged:
+			result.Differences = append(result.Differences, diffEntry{Key: d.Key, Status: "changed", Left: describe(d.Left), Right: describe(d.Right)})
+		}
+	}
+	return result
+}
+
+func printDiff(diffs []service.KeyDiff, leftLabel, rightLabel string, reveal bool, salt []byte) {
+	describe := func(value string) string {
+		if reveal {
+			return fmt.Sprintf("%q", value)
+		}
+		return fmt.Sprintf("%s (%d chars)", service.Fingerprint(value, salt), len(value))
+	}
+
+	fmt.Printf("Comparing %s -> %s\n\n", leftLabel, rightLabel)
+
+	var onlyLeft, onlyRight, changed, same int
+	for _, d := range diffs {
+		switch d.Kind {
+		case service.DiffOnlyLeft:
+			onlyLeft++
+			fmt.Printf("  - %-30s only in %s  %s\n", d.Key, leftLabel, describe(d.Left))
+		case service.DiffOnlyRight:
+			onlyRight++
+			fmt.Printf("  + %-30s only in %s  %s\n", d.Key, rightLabel, describe(d.Right))
+		case service.DiffChanged:
+			changed++
+			fmt.Printf("  ~ %-30s %s -> %s\n", d.Key, describe(d.Left), describe(d.Right))
+		default:
+			same++
+		}
+	}
+
+	if onlyLeft+onlyRight+changed == 0 {
+		fmt.Printf("No differences (%d identical keys)\n", same)
+		return
+	}
+
+	fmt.Printf("\n%d missing in %s, %d missing in %s, %d changed, %d identical\n",
+		onlyLeft, rightLabel, onlyRight, leftLabel, changed, same)
+}
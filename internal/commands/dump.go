@@ -0,0 +1,304 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"envy/internal/auth"
+	"envy/internal/domain"
+	"envy/internal/service"
+	"envy/internal/storage"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+type dumpResult struct {
+	Path            string `json:"path"`
+	Projects        int    `json:"projects"`
+	Keys            int    `json:"keys"`
+	Versions        int    `json:"versions"`
+	IncludesHistory bool   `json:"includes_history"`
+	Encrypted       bool   `json:"encrypted"`
+}
+
+type restoreResult struct {
+	Source     string                  `json:"source"`
+	Restored   bool                    `json:"restored"`
+	Equivalent bool                    `json:"equivalent"`
+	Comparison service.VaultComparison `json:"comparison"`
+}
+
+var dumpCmd = &cobra.Command{
+	Use:   "dump [project[:env]...] [--all] [--include-history] [--encrypt] [-o file]",
+	Short: "Write projects, optionally with history, to a JSON dump",
+	Long: `Write a complete copy of projects to a versioned JSON document: every
+key with its value, author, timestamp, description and layout, and with
+--include-history every earlier version too. 'envy restore' rebuilds a
+vault from it.
+
+Name projects, with all their environments unless one is given, or use
+--all. The dump is printed to stdout unless -o is given; a plain-text dump
+isn't printed to a terminal. With --encrypt it is encrypted to a passphrase,
+asked for twice.
+
+Examples:
+  envy dump --all --include-history --encrypt -o envy-backup.json
+  envy dump myapp:prod shared -o myapp.json
+  envy dump --all | gzip > dump.json.gz`,
+	RunE:              runDumpCommand,
+	ValidArgsFunction: completeProjectFlag,
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <dump|-> [--compare] [--yes]",
+	Short: "Rebuild the vault from a dump",
+	Long: `Replace the projects in the vault with those of a dump made by 'envy
+dump', then read the vault back and compare it with the dump. Without a
+vault a new one is created. The old vault is kept as keys.json.backup.
+
+--compare only compares the dump with the current vault, without changing
+anything, and exits with status 10 when they differ.
+
+Examples:
+  envy restore envy-backup.json
+  envy restore envy-backup.json --compare
+  gunzip -c dump.json.gz | envy restore - --yes`,
+	Args: usageArgs(cobra.ExactArgs(1)),
+	RunE: runRestoreCommand,
+}
+
+func init() {
+	RootCmd.AddCommand(dumpCmd)
+	dumpCmd.Flags().Bool("all", false, "Dump every project")
+	dumpCmd.Flags().Bool("include-history", false, "Include every earlier version of each key")
+	dumpCmd.Flags().Bool("encrypt", false, "Encrypt the dump to a passphrase")
+	dumpCmd.Flags().StringP("out", "o", "", "Write the dump to this file instead of stdout")
+	dumpCmd.Flags().Bool("force", false, "Overwrite an existing file without asking")
+
+	RootCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().Bool("compare", false, "Only compare the dump with the vault")
+	restoreCmd.Flags().BoolP("yes", "y", false, "Replace existing projects without asking")
+}
+
+func runDumpCommand(cmd *cobra.Command, args []string) error {
+	all, _ := cmd.Flags().GetBool("all")
+	includeHistory, _ := cmd.Flags().GetBool("include-history")
+	encrypt, _ := cmd.Flags().GetBool("encrypt")
+	out, _ := cmd.Flags().GetString("out")
+	force, _ := cmd.Flags().GetBool("force")
+
+	if all == (len(args) > 0) {
+		return usageErrorf("name the projects to dump, or use --all")
+	}
+	toStdout := out == "" || out == "-"
+	if toStdout && !encrypt && term.IsTerminal(int(os.Stdout.Fd())) {
+		return usageErrorf("not printing plain-text secrets to the terminal; use -o, --encrypt or a redirect")
+	}
+	if !toStdout && !force {
+		if _, err := os.Stat(out); err == nil {
+			ok, err := confirm(fmt.Sprintf("%s already exists. Overwrite? [y/N]: ", out))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("dump %w (use --force to overwrite)", ErrCancelled)
+			}
+		}
+	}
+
+	projects, _, err := unlockVault()
+	if err != nil {
+		return err
+	}
+	if !all {
+		if projects, err = selectProjects(projects, args); err != nil {
+			return err
+		}
+	}
+
+	var passphrase string
+	if encrypt {
+		if passphrase, err = promptNewPassphrase(); err != nil {
+			return err
+		}
+	}
+
+	dump := service.NewDump(projects, includeHistory, time.Now())
+	data, err := service.MarshalDump(dump, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to write dump: %w", err)
+	}
+
+	result := dumpResult{Path: out, IncludesHistory: includeHistory, Encrypted: encrypt}
+	result.Projects, result.Keys, result.Versions = dump.Count()
+
+	if toStdout {
+		if _, err := os.Stdout.Write(data); err != nil {
+			return err
+		}
+		infof("Dumped %d projects, %d keys and %d versions.\n", result.Projects, result.Keys, result.Versions)
+		return nil
+	}
+
+	if err := writePrivateFile(out, data); err != nil {
+		return err
+	}
+	printResult(result, func() {
+		infof("Dumped %d projects, %d keys and %d versions to %s", result.Projects, result.Keys, result.Versions, out)
+		if encrypt {
+			infof(", encrypted")
+		}
+		infof(".\n")
+	})
+	return nil
+}
+
+// selectProjects returns the projects named by specs, all environments of a
+// name unless the spec gives one
+func selectProjects(projects []domain.Project, specs []string) ([]domain.Project, error) {
+	var selected []domain.Project
+	seen := make(map[int]bool)
+	for _, spec := range specs {
+		name, env := service.ParseProjectSpec(spec)
+		found := false
+		for i, p := range projects {
+			if strings.EqualFold(p.Name, name) && (env == "" || p.Environment == env) {
+				found = true
+				if !seen[i] {
+					seen[i] = true
+					selected = append(selected, p)
+				}
+			}
+		}
+		if !found {
+			if env == "" {
+				return nil, withContext(fmt.Errorf("project '%s' %w", name, domain.ErrNotFound), name, "", "")
+			}
+			return nil, withContext(fmt.Errorf("project '%s' (%s) %w", name, env, domain.ErrNotFound), name, env, "")
+		}
+	}
+	return selected, nil
+}
+
+func runRestoreCommand(cmd *cobra.Command, args []string) error {
+	compareOnly, _ := cmd.Flags().GetBool("compare")
+	yes, _ := cmd.Flags().GetBool("yes")
+	source := args[0]
+
+	data, err := readInput(source)
+	if err != nil {
+		return err
+	}
+	dump, err := service.ParseDump(data, func() (string, error) {
+		return auth.PromptPassword("Dump passphrase: ")
+	})
+	if err != nil {
+		return &CommandError{Code: ExitUsage, Err: fmt.Errorf("%s: %w", source, err)}
+	}
+	if err := service.ValidateDump(dump); err != nil {
+		return &CommandError{Code: ExitUsage, Err: fmt.Errorf("%s: %w", source, err)}
+	}
+
+	result := restoreResult{Source: source}
+
+	if compareOnly {
+		projects, _, err := unlockVault()
+		if err != nil {
+			return err
+		}
+		result.Comparison = service.CompareVaults(dump, projects)
+		result.Equivalent = result.Comparison.Equivalent()
+		printResult(result, func() { printComparison(result) })
+		if !result.Equivalent {
+			return &CommandError{Code: ExitDrift, Err: fmt.Errorf("the vault differs from %s", source), quiet: true}
+		}
+		return nil
+	}
+
+	projects, key, err := unlockOrCreateVault()
+	if err != nil {
+		return err
+	}
+	if len(projects) > 0 && !yes {
+		ok, err := confirm(fmt.Sprintf("Replace the %d projects in the vault with the %d in the dump? [y/N]: ", len(projects), len(dump.Projects)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("restore %w", ErrCancelled)
+		}
+	}
+
+	if err := storage.CreateBackup(); err != nil {
+		return vaultError("failed to back up vault", err)
+	}
+	if err := saveVault(dump.Projects, key); err != nil {
+		return err
+	}
+	result.Restored = true
+
+	// Read the vault back from disk, so the report covers what was written
+	restored, err := storage.LoadWithKey(key)
+	if err != nil {
+		return vaultError("failed to read restored vault", err)
+	}
+	result.Comparison = service.CompareVaults(dump, restored)
+	result.Equivalent = result.Comparison.Equivalent()
+
+	printResult(result, func() {
+		infof("Restored %d projects from %s.\n", len(dump.Projects), source)
+		printComparison(result)
+	})
+	if !result.Equivalent {
+		return &CommandError{Code: ExitFailure, Err: fmt.Errorf("the restored vault differs from %s", source), quiet: true}
+	}
+	return nil
+}
+
+func printComparison(result restoreResult) {
+	c := result.Comparison
+	infof("Comparison with %s:\n", result.Source)
+	infof("  %-10s %5d\n", "projects", c.Projects)
+	infof("  %-10s %5d\n", "keys", c.Keys)
+	infof("  %-10s %5d\n", "versions", c.Versions)
+	for _, row := range []struct {
+		label   string
+		entries []string
+	}{
+		{"missing", c.Missing},
+		{"extra", c.Extra},
+		{"different", c.Different},
+	} {
+		infof("  %-10s %5d\n", row.label, len(row.entries))
+		for _, entry := range row.entries {
+			infof("    %s\n", entry)
+		}
+	}
+	if result.Equivalent {
+		infof("The vault matches the dump.\n")
+	} else {
+		infof("The vault does NOT match the dump.\n")
+	}
+}
+
+// promptNewPassphrase asks for a dump passphrase twice
+func promptNewPassphrase() (string, error) {
+	passphrase, err := auth.PromptPassword("Dump passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if len(passphrase) < 8 {
+		return "", usageErrorf("passphrase must be at least 8 characters")
+	}
+	again, err := auth.PromptPassword("Confirm passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase != again {
+		return "", usageErrorf("passphrases do not match")
+	}
+	return passphrase, nil
+}
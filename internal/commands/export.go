@@ -1,55 +1,174 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 
-	"envy/internal/auth"
-	"envy/internal/storage"
+	"envy/internal/service"
 )
 
-func RunExport(projectName string) {
-	password, err := auth.PromptPassword("Enter master password: ")
+type exportResult struct {
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+	Format      string `json:"format"`
+	Path        string `json:"path"`
+	Keys        int    `json:"keys"`
+
+	// Set with --merge
+	Updated []string `json:"updated,omitempty"`
+	Added   []string `json:"added,omitempty"`
+	Kept    []string `json:"kept,omitempty"`
+}
+
+// exportOptions are the flags of envy --export
+type exportOptions struct {
+	raw            bool
+	format         string
+	out            string
+	force          bool
+	merge          bool
+	gitignoreCheck bool
+}
+
+// exportFileNames is where each format is written
+var exportFileNames = map[string]string{
+	"dotenv":     ".env",
+	"json":       "env.json",
+	"yaml":       "env.yaml",
+	"shell":      "env.sh",
+	"fish":       "env.fish",
+	"docker":     "docker.env",
+	"systemd":    "systemd.env",
+	"properties": "env.properties",
+	"toml":       "env.toml",
+}
+
+func RunExport(projectName, environment string, opts exportOptions) error {
+	fileName, ok := exportFileNames[opts.format]
+	if !ok {
+		return usageErrorf("invalid --format '%s' (must be one of %s)", opts.format, strings.Join(service.ExportFormats, ", "))
+	}
+	if opts.out != "" {
+		fileName = opts.out
+	}
+	toStdout := fileName == "-"
+
+	if opts.merge && opts.format != "dotenv" {
+		return usageErrorf("--merge only works with the dotenv format")
+	}
+	if toStdout && (opts.merge || opts.force) {
+		return usageErrorf("--merge and --force need a file, not -o -")
+	}
+
+	existing, err := readExisting(fileName, toStdout)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return
+		return err
+	}
+	if existing != nil && !opts.merge && !opts.force {
+		ok, err := confirm(fmt.Sprintf("%s already exists. Overwrite? [y/N]: ", fileName))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("export %w (use --force to overwrite or --merge to update only vault keys)", ErrCancelled)
+		}
 	}
 
-	projects, _, err := storage.Load(password)
+	projects, _, err := unlockVault()
 	if err != nil {
-		fmt.Printf("Error loading vault: %v\n", err)
-		return
+		return err
+	}
+
+	foundProject, err := resolveProject(projects, projectName, environment)
+	if err != nil {
+		return err
 	}
 
-	foundIdx := -1
-	for i, p := range projects {
-		if strings.EqualFold(p.Name, projectName) {
-			foundIdx = i
-			break
+	if !opts.raw {
+		resolver := service.NewResolver(projects)
+		resolved, err := resolver.Project(*foundProject)
+		if err != nil {
+			return withContext(err, foundProject.Name, foundProject.Environment, "")
 		}
+		warnUnresolved(resolver)
+		foundProject = &resolved
 	}
 
-	if foundIdx < 0 {
-		fmt.Printf("Project '%s' not found in vault.\n", projectName)
-		return
+	result := exportResult{
+		Project:     foundProject.Name,
+		Environment: foundProject.Environment,
+		Format:      opts.format,
+		Path:        fileName,
+		Keys:        len(foundProject.Keys),
+	}
+
+	var content []byte
+	if opts.merge && existing != nil {
+		merged := service.MergeDotenv(existing, *foundProject)
+		content = merged.Content
+		result.Updated, result.Added, result.Kept = merged.Updated, merged.Added, merged.Kept
+	} else {
+		content, err = service.FormatProject(opts.format, *foundProject)
+		if err != nil {
+			return withContext(fmt.Errorf("can't export as %s: %w", opts.format, err), foundProject.Name, foundProject.Environment, "")
+		}
 	}
 
-	foundProject := &projects[foundIdx]
+	if toStdout {
+		_, err := os.Stdout.Write(content)
+		return err
+	}
 
-	var content strings.Builder
-	content.WriteString(fmt.Sprintf("# Exported from Envy - Project: %s (%s)\n", foundProject.Name, foundProject.Environment))
+	if err := writePrivateFile(fileName, content); err != nil {
+		return withContext(err, foundProject.Name, foundProject.Environment, "")
+	}
 
-	for _, key := range foundProject.Keys {
-		line := fmt.Sprintf("%s=%s\n", key.Key, key.Current.Value)
-		content.WriteString(line)
+	infof("Warning: Exported file contains secrets in plain text. Keep it secure!\n")
+	if opts.gitignoreCheck {
+		warnIfNotIgnored(fileName)
 	}
+	printResult(result, func() {
+		if opts.merge && existing != nil {
+			infof("Merged project '%s' into %s: %d updated, %d added, %d local keys kept\n",
+				foundProject.Name, fileName, len(result.Updated), len(result.Added), len(result.Kept))
+			return
+		}
+		infof("Exported project '%s' to %s\n", foundProject.Name, fileName)
+	})
+	return nil
+}
 
-	fileName := ".env"
-	if err := os.WriteFile(fileName, []byte(content.String()), 0o600); err != nil {
-		fmt.Printf("Error writing .env file: %v\n", err)
+// readExisting returns the contents of the export target, or nil if it
+// doesn't exist yet
+func readExisting(path string, toStdout bool) ([]byte, error) {
+	if toStdout {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &CommandError{Code: ExitUsage, Err: fmt.Errorf("failed to read %s: %w", path, err)}
+	}
+	return data, nil
+}
+
+// warnIfNotIgnored warns when path is inside a git work tree and not covered
+// by its ignore rules. Nothing is reported outside a repository or without git.
+func warnIfNotIgnored(path string) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
 		return
 	}
-	fmt.Println("Warning: Exported .env file contains secrets in plain text. Keep it secure!")
-	fmt.Printf("Exported project '%s' to .env\n", foundProject.Name)
+
+	err = exec.Command("git", "-C", filepath.Dir(abs), "check-ignore", "-q", "--", abs).Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		infof("Warning: %s is not ignored by git. Add it to .gitignore so it isn't committed.\n", path)
+	}
 }
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"fmt"
+
+	"envy/internal/domain"
+	"envy/internal/service"
+
+	"github.com/spf13/cobra"
+)
+
+type getResult struct {
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+}
+
+var getCmd = &cobra.Command{
+	Use:   "get [project[:env]] [KEY]",
+	Short: "Print the value of a secret",
+	Long: `Print the current value of a secret to stdout.
+
+References to other keys in the value, like ${DB_HOST} or
+${ref:shared/prod/SENTRY_DSN}, are expanded. Use --raw to print the value
+exactly as stored.
+
+Examples:
+  envy get myapp API_KEY
+  envy get myapp:prod DATABASE_URL
+  envy get myapp DATABASE_URL --raw
+  export TOKEN="$(envy get myapp TOKEN)"`,
+	Args:              usageArgs(cobra.ExactArgs(2)),
+	RunE:              runGetCommand,
+	ValidArgsFunction: completeProjectThenKey,
+}
+
+func init() {
+	RootCmd.AddCommand(getCmd)
+	getCmd.Flags().StringP("env", "e", "", "Environment of the project (dev, stage, prod)")
+	getCmd.Flags().Bool("raw", false, "Print the stored value without expanding references")
+	getCmd.RegisterFlagCompletionFunc("env", completeEnvironments)
+}
+
+func runGetCommand(cmd *cobra.Command, args []string) error {
+	projectName, environment := service.ParseProjectSpec(args[0])
+	keyName := args[1]
+	if environment == "" {
+		environment, _ = cmd.Flags().GetString("env")
+	}
+	raw, _ := cmd.Flags().GetBool("raw")
+
+	projects, _, err := unlockVault()
+	if err != nil {
+		return err
+	}
+
+	project, err := resolveProject(projects, projectName, environment)
+	if err != nil {
+		return err
+	}
+
+	apiKey := findKey(project, keyName)
+	if apiKey == nil {
+		err := fmt.Errorf("key '%s' %w in project '%s' (%s)", keyName, domain.ErrNotFound, project.Name, project.Environment)
+		return withContext(err, project.Name, project.Environment, keyName)
+	}
+
+	value := apiKey.Current.Value
+	if !raw {
+		resolver := service.NewResolver(projects)
+		value, err = resolver.Value(*project, apiKey.Key)
+		if err != nil {
+			return withContext(err, project.Name, project.Environment, apiKey.Key)
+		}
+		warnUnresolved(resolver)
+	}
+
+	result := getResult{
+		Project:     project.Name,
+		Environment: project.Environment,
+		Key:         apiKey.Key,
+		Value:       value,
+	}
+	printResult(result, func() {
+		fmt.Println(value)
+	})
+	return nil
+}
+
+// warnUnresolved prints the references resolver left as they are
+func warnUnresolved(resolver *service.Resolver) {
+	for _, warning := range resolver.Warnings() {
+		infof("Warning: %s\n", warning)
+	}
+}
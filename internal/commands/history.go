@@ -0,0 +1,198 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"envy/internal/domain"
+	"envy/internal/service"
+
+	"github.com/spf13/cobra"
+)
+
+const historyTimeFormat = "02-01-2006 15:04"
+
+var historyCmd = &cobra.Command{
+	Use:   "history [project] [KEY]",
+	Short: "Show the version history of a secret",
+	Long: `List every stored version of a secret, oldest first.
+
+Values are masked. The version numbers shown can be passed to
+'envy rollback' to make an old value current again.
+
+Examples:
+  envy history myapp API_KEY
+  envy history myapp DATABASE_URL -e prod`,
+	Args:              usageArgs(cobra.ExactArgs(2)),
+	RunE:              runHistoryCommand,
+	ValidArgsFunction: completeProjectThenKey,
+}
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback [project] [KEY] --to N",
+	Short: "Restore an earlier version of a secret",
+	Long: `Make version N of a secret current again.
+
+The present value is saved to history first, exactly as an update would,
+so a rollback can itself be rolled back. Use 'envy history' to find N.
+
+Examples:
+  envy rollback myapp API_KEY --to 2
+  envy rollback myapp DATABASE_URL --to 1 -e prod`,
+	Args:              usageArgs(cobra.ExactArgs(2)),
+	RunE:              runRollbackCommand,
+	ValidArgsFunction: completeProjectThenKey,
+}
+
+func init() {
+	RootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().StringP("env", "e", "dev", "Environment (dev, stage, prod)")
+	historyCmd.RegisterFlagCompletionFunc("env", completeEnvironments)
+
+	RootCmd.AddCommand(rollbackCmd)
+	rollbackCmd.Flags().StringP("env", "e", "dev", "Environment (dev, stage, prod)")
+	rollbackCmd.Flags().Int("to", 0, "Version number to restore (see 'envy history')")
+	rollbackCmd.MarkFlagRequired("to")
+	rollbackCmd.RegisterFlagCompletionFunc("env", completeEnvironments)
+}
+
+func runHistoryCommand(cmd *cobra.Command, args []string) error {
+	projectName, keyName := args[0], args[1]
+	environment, _ := cmd.Flags().GetString("env")
+
+	if err := domain.ValidateEnvironment(environment); err != nil {
+		return fmt.Errorf("invalid environment: %w", err)
+	}
+
+	projects, _, err := unlockVault()
+	if err != nil {
+		return err
+	}
+
+	project, err := findProject(projects, projectName, environment)
+	if err != nil {
+		return err
+	}
+
+	apiKey := findKey(project, keyName)
+	if apiKey == nil {
+		err := fmt.Errorf("key '%s' %w in project '%s' (%s)", keyName, domain.ErrNotFound, project.Name, project.Environment)
+		return withContext(err, project.Name, project.Environment, keyName)
+	}
+
+	result := historyResult{
+		Project:     project.Name,
+		Environment: project.Environment,
+		Key:         apiKey.Key,
+		Versions:    []historyVersion{},
+		Current:     newHistoryVersion(0, apiKey.Current),
+	}
+	for i, version := range apiKey.History {
+		result.Versions = append(result.Versions, newHistoryVersion(i+1, version))
+	}
+
+	printResult(result, func() {
+		fmt.Printf("History of '%s' in project '%s' (%s)\n\n", apiKey.Key, project.Name, project.Environment)
+		fmt.Printf("  %-8s %-17s %-14s %s\n", "VERSION", "CREATED", "BY", "VALUE")
+
+		for i, version := range apiKey.History {
+			printHistoryRow(fmt.Sprintf("%d", i+1), version)
+		}
+		printHistoryRow("current", apiKey.Current)
+
+		if len(apiKey.History) == 0 {
+			fmt.Println("\nNo previous versions")
+		}
+	})
+	return nil
+}
+
+type historyVersion struct {
+	Version   int       `json:"version,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy string    `json:"created_by"`
+	Value     string    `json:"masked_value"`
+}
+
+type historyResult struct {
+	Project     string           `json:"project"`
+	Environment string           `json:"environment"`
+	Key         string           `json:"key"`
+	Versions    []historyVersion `json:"versions"`
+	Current     historyVersion   `json:"current"`
+}
+
+func newHistoryVersion(number int, version domain.SecretVersion) historyVersion {
+	return historyVersion{
+		Version:   number,
+		CreatedAt: version.CreatedAt,
+		CreatedBy: version.CreatedBy,
+		Value:     domain.MaskValue(version.Value),
+	}
+}
+
+func printHistoryRow(label string, version domain.SecretVersion) {
+	createdBy := version.CreatedBy
+	if createdBy == "" {
+		createdBy = "-"
+	}
+	fmt.Printf("  %-8s %-17s %-14s %s\n",
+		label,
+		version.CreatedAt.Format(historyTimeFormat),
+		createdBy,
+		domain.MaskValue(version.Value),
+	)
+}
+
+func runRollbackCommand(cmd *cobra.Command, args []string) error {
+	projectName, keyName := args[0], args[1]
+	environment, _ := cmd.Flags().GetString("env")
+	version, _ := cmd.Flags().GetInt("to")
+
+	if err := domain.ValidateEnvironment(environment); err != nil {
+		return fmt.Errorf("invalid environment: %w", err)
+	}
+
+	projects, key, err := unlockVault()
+	if err != nil {
+		return err
+	}
+
+	project, err := findProject(projects, projectName, environment)
+	if err != nil {
+		return err
+	}
+
+	vault := service.NewVaultService(projects, key)
+	if err := vault.RollbackKey(project.Name, project.Environment, keyName, version); err != nil {
+		return withContext(err, project.Name, project.Environment, keyName)
+	}
+
+	if err := vault.Save(); err != nil {
+		return withContext(vaultError("failed to save vault", err), project.Name, project.Environment, keyName)
+	}
+
+	result := rollbackResult{Project: project.Name, Environment: project.Environment, Key: keyName, Version: version}
+	printResult(result, func() {
+		infof("Rolled back '%s' in project '%s' (%s) to version %d\n", keyName, project.Name, project.Environment, version)
+		infof("  Previous value saved to history\n")
+	})
+	return nil
+}
+
+type rollbackResult struct {
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+	Key         string `json:"key"`
+	Version     int    `json:"restored_version"`
+}
+
+// findKey returns the key with the given name or nil
+func findKey(project *domain.Project, keyName string) *domain.APIKey {
+	for i := range project.Keys {
+		if project.Keys[i].Key == keyName {
+			return &project.Keys[i]
+		}
+	}
+	return nil
+}
@@ -2,151 +2,538 @@ package commands
 
 import (
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"envy/internal/auth"
 	"envy/internal/domain"
+	"envy/internal/service"
 	"envy/internal/storage"
 
-	"github.com/hashicorp/go-envparse"
+	"github.com/spf13/cobra"
 )
 
-func RunImport(filePath string) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		fmt.Printf("Error opening file: %v\n", err)
-		return
+type importResult struct {
+	Source      string `json:"source,omitempty"`
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+	Created     bool   `json:"created,omitempty"`
+	Strategy    string `json:"strategy"`
+	Imported    int    `json:"imported"`
+	service.ImportSummary
+}
+
+type importTreeResult struct {
+	Root     string                   `json:"root"`
+	Strategy string                   `json:"strategy"`
+	Files    []importResult           `json:"files"`
+	Skipped  []service.SkippedEnvFile `json:"skipped,omitempty"`
+	Applied  bool                     `json:"applied"`
+}
+
+// importOptions are the flags of envy import
+type importOptions struct {
+	project   string
+	env       string
+	strategy  string
+	format    string
+	separator string
+	yes       bool
+	preview   bool
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <file|-> [-p project] [-e env] [--strategy strategy] | --tree dir",
+	Short: "Import secrets from a .env, JSON, YAML, compose or Kubernetes file",
+	Long: `Import the keys of a file into a project, creating the project if
+needed. Without -p the project name and environment are asked for.
+
+Formats (detected from the file name and contents unless --format is set):
+  dotenv   KEY=value lines
+  json     an object; nested objects are flattened, {"db": {"host": ..}}
+           becomes db_host (see --separator)
+  yaml     like json, several documents are merged
+  compose  the environment section of every docker-compose service
+  k8s      data (base64) and stringData of every Kubernetes Secret
+
+Every compose service and every Secret is imported into the project of the
+same name, so -p can't be used when the file holds several of them.
+
+Strategies for a project that already exists:
+  merge             add new keys and update changed ones, keeping the old
+                    value in history
+  replace           like merge, then remove keys the file doesn't contain
+  skip-existing     only add keys the project doesn't have yet
+  fail-on-conflict  change nothing if an existing key has another value
+
+Without --strategy, importing into an existing project asks before
+replacing it.
+
+--tree imports every .env.<suffix> file below a directory. The project is
+the file's directory relative to it (its own name for files directly in it)
+and the environment comes from the suffix: development and dev become dev,
+staging and stage become stage, production and prod become prod. More
+suffixes can be mapped in the config file. The full plan is shown before
+anything is written, and everything is saved at once, or nothing if a file
+fails. The default strategy here is merge.
+
+Examples:
+  envy import .env -p myapp -e dev --strategy merge
+  envy import .env.production -p myapp -e prod --strategy fail-on-conflict
+  envy import config.json -p myapp --separator __
+  envy import docker-compose.yml -e dev --strategy merge
+  kubectl get secret api -o yaml | envy import - --format k8s -e prod
+  envy import --tree ./services --preview
+  envy import --tree ./services --strategy skip-existing --yes
+  envy import .env`,
+	Args: usageArgs(cobra.MaximumNArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var opts importOptions
+		opts.project, _ = cmd.Flags().GetString("project")
+		opts.env, _ = cmd.Flags().GetString("env")
+		opts.strategy, _ = cmd.Flags().GetString("strategy")
+		opts.format, _ = cmd.Flags().GetString("format")
+		opts.separator, _ = cmd.Flags().GetString("separator")
+		opts.yes, _ = cmd.Flags().GetBool("yes")
+		opts.preview, _ = cmd.Flags().GetBool("preview")
+
+		if tree, _ := cmd.Flags().GetString("tree"); tree != "" {
+			for _, flag := range []string{"project", "env", "format"} {
+				if cmd.Flags().Changed(flag) {
+					return usageErrorf("--%s can't be used with --tree", flag)
+				}
+			}
+			if len(args) > 0 {
+				return usageErrorf("--tree takes no file argument")
+			}
+			return RunImportTree(tree, opts)
+		}
+
+		if opts.yes || opts.preview {
+			return usageErrorf("--yes and --preview only work with --tree")
+		}
+		if len(args) == 0 {
+			return usageErrorf("import needs a file, or --tree with a directory")
+		}
+		return RunImport(args[0], opts)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(importCmd)
+	importCmd.Flags().StringP("project", "p", "", "Project to import into (asked for if not set)")
+	importCmd.Flags().StringP("env", "e", "", "Environment of the project (default dev)")
+	importCmd.Flags().String("strategy", "", "How to treat existing keys: "+strings.Join(service.ImportStrategies, ", "))
+	importCmd.Flags().String("format", "auto", "Format of the file: auto, "+strings.Join(service.ImportFormats, ", "))
+	importCmd.Flags().String("separator", "_", "Separator joining the keys of nested JSON and YAML objects")
+	importCmd.Flags().String("tree", "", "Import every .env.<environment> file below this directory")
+	importCmd.Flags().BoolP("yes", "y", false, "With --tree, import without asking for confirmation")
+	importCmd.Flags().Bool("preview", false, "With --tree, only show what would be imported")
+	importCmd.MarkFlagDirname("tree")
+	importCmd.RegisterFlagCompletionFunc("project", completeProjectFlag)
+	importCmd.RegisterFlagCompletionFunc("env", completeEnvironments)
+	importCmd.RegisterFlagCompletionFunc("strategy", cobra.FixedCompletions(service.ImportStrategies, cobra.ShellCompDirectiveNoFileComp))
+	importCmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(append([]string{"auto"}, service.ImportFormats...), cobra.ShellCompDirectiveNoFileComp))
+}
+
+func RunImport(filePath string, opts importOptions) error {
+	if opts.strategy != "" && !slices.Contains(service.ImportStrategies, opts.strategy) {
+		return usageErrorf("invalid --strategy '%s' (must be one of %s)", opts.strategy, strings.Join(service.ImportStrategies, ", "))
+	}
+	if opts.format != "" && opts.format != "auto" && !slices.Contains(service.ImportFormats, opts.format) {
+		return usageErrorf("invalid --format '%s' (must be auto or one of %s)", opts.format, strings.Join(service.ImportFormats, ", "))
 	}
-	defer file.Close()
 
-	envMap, err := envparse.Parse(file)
+	data, err := readInput(filePath)
 	if err != nil {
-		fmt.Printf("Error parsing .env file: %v\n", err)
-		return
+		return err
 	}
 
-	if len(envMap) == 0 {
-		fmt.Println("File is empty or contains no valid keys.")
-		return
+	format := opts.format
+	if format == "" || format == "auto" {
+		format = service.DetectImportFormat(filePath, data)
+	}
+	separator := opts.separator
+	if separator == "" {
+		separator = "_"
 	}
 
-	name, err := auth.PromptText("Enter Project Name: ")
+	docs, err := service.ParseImport(format, data, separator)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return
+		return &CommandError{Code: ExitUsage, Err: fmt.Errorf("failed to parse %s as %s: %w", filePath, format, err)}
 	}
-	name = strings.TrimSpace(name)
 
-	if err := domain.ValidateProjectName(name); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return
+	batches, err := importBatches(filePath, docs, opts)
+	if err != nil {
+		return err
 	}
 
-	env, err := auth.PromptText("Environment (prod/dev/stage) [dev]: ")
+	projects, key, err := unlockOrCreateVault()
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return
+		return err
 	}
-	env = strings.TrimSpace(env)
-	if env == "" {
-		env = domain.EnvDev
+
+	var results []importResult
+	for _, batch := range batches {
+		result, err := applyImport(&projects, batch, opts.strategy)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
 	}
 
-	if err := domain.ValidateEnvironment(env); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return
+	if err := saveVault(projects, key); err != nil {
+		if len(batches) == 1 {
+			return withContext(err, batches[0].project, batches[0].env, "")
+		}
+		return err
 	}
 
-	firstRun, err := storage.IsFirstRun()
+	printImportResults(filePath, results)
+	return nil
+}
+
+// RunImportTree imports every .env.<suffix> file below root in a single
+// vault save, after showing the plan
+func RunImportTree(root string, opts importOptions) error {
+	strategy := opts.strategy
+	if strategy == "" {
+		strategy = service.ImportMerge
+	}
+	if !slices.Contains(service.ImportStrategies, strategy) {
+		return usageErrorf("invalid --strategy '%s' (must be one of %s)", strategy, strings.Join(service.ImportStrategies, ", "))
+	}
+
+	suffixes, err := environmentSuffixes()
 	if err != nil {
-		fmt.Printf("Error checking vault status: %v\n", err)
-		return
+		return err
 	}
 
-	var password string
-	var projects []domain.Project
-	var key []byte
+	files, skipped, err := service.FindEnvFiles(root, suffixes)
+	if err != nil {
+		return &CommandError{Code: ExitUsage, Err: fmt.Errorf("failed to read %s: %w", root, err)}
+	}
+	if len(files) == 0 {
+		return usageErrorf("no .env.<environment> files found in %s", root)
+	}
 
-	if firstRun {
-		fmt.Println("No vault found. Creating new vault...")
-		password, err = auth.PromptNewPassword()
-		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			return
+	var batches []importBatch
+	targets := make(map[string]string)
+	for _, file := range files {
+		target := file.Project + ":" + file.Environment
+		if other, ok := targets[target]; ok {
+			return usageErrorf("%s and %s both map to project '%s' (%s)", other, file.Path, file.Project, file.Environment)
 		}
+		targets[target] = file.Path
 
-		if err := storage.Initialize(password); err != nil {
-			fmt.Printf("Error initializing vault: %v\n", err)
-			return
+		data, err := readInput(file.Path)
+		if err != nil {
+			return err
 		}
-
-		projects, key, err = storage.Load(password)
+		docs, err := service.ParseImport("dotenv", data, "")
 		if err != nil {
-			fmt.Printf("Error loading vault: %v\n", err)
-			return
+			return &CommandError{Code: ExitUsage, Err: fmt.Errorf("failed to parse %s: %w", file.Path, err)}
 		}
-	} else {
-		password, err = auth.PromptPassword("Enter master password: ")
+		batches = append(batches, newImportBatch(file.Path, file.Project, file.Environment, docs[0]))
+	}
+
+	projects, key, err := unlockOrCreateVault()
+	if err != nil {
+		return err
+	}
+
+	// The whole plan is applied in memory, so its counts are exact, and only
+	// saved once confirmed
+	result := importTreeResult{Root: root, Strategy: strategy, Skipped: skipped}
+	for _, batch := range batches {
+		imported, err := applyImport(&projects, batch, strategy)
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			return
+			return fmt.Errorf("%s: %w", batch.source, err)
 		}
+		result.Files = append(result.Files, imported)
+	}
 
-		projects, key, err = storage.Load(password)
+	if opts.preview {
+		printResult(result, func() { printImportPlan(os.Stdout, result) })
+		return nil
+	}
+
+	if !opts.yes {
+		printImportPlan(os.Stderr, result)
+		ok, err := confirm(fmt.Sprintf("Import %d files? [y/N]: ", len(result.Files)))
 		if err != nil {
-			fmt.Printf("Error loading vault: %v\n", err)
-			return
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("import %w", ErrCancelled)
+		}
+	}
+
+	if err := saveVault(projects, key); err != nil {
+		return err
+	}
+
+	result.Applied = true
+	printResult(result, func() {
+		if opts.yes {
+			printImportPlan(os.Stderr, result)
+		}
+		infof("Imported %d files from %s.\n", len(result.Files), root)
+	})
+	return nil
+}
+
+// environmentSuffixes returns the built-in suffix mapping with the config
+// file's entries on top
+func environmentSuffixes() (map[string]string, error) {
+	suffixes := maps.Clone(service.DefaultEnvironmentSuffixes)
+	for suffix, env := range appConfig.Import.Environments {
+		if env != "" {
+			if err := domain.ValidateEnvironment(env); err != nil {
+				return nil, usageErrorf("config import.environments['%s']: %v", suffix, err)
+			}
 		}
+		suffixes[suffix] = env
 	}
+	return suffixes, nil
+}
 
-	for i, p := range projects {
-		if p.Name == name && p.Environment == env {
-			fmt.Printf("Project '%s' (%s) already exists. Overwrite? [y/N]: ", name, env)
-			var response string
-			fmt.Scanln(&response)
-			if strings.ToLower(response) != "y" {
-				fmt.Println("Import cancelled.")
-				return
+func printImportPlan(w io.Writer, result importTreeResult) {
+	fmt.Fprintf(w, "Import plan for %s (strategy %s):\n\n", result.Root, result.Strategy)
+	for _, file := range result.Files {
+		var counts []string
+		for _, c := range []struct {
+			label string
+			keys  []string
+		}{
+			{"added", file.Added},
+			{"updated", file.Updated},
+			{"unchanged", file.Unchanged},
+			{"skipped", file.Skipped},
+			{"removed", file.Removed},
+		} {
+			if len(c.keys) > 0 {
+				counts = append(counts, fmt.Sprintf("%d %s", len(c.keys), c.label))
 			}
-			projects = append(projects[:i], projects[i+1:]...)
-			break
 		}
+		target := fmt.Sprintf("%s (%s)", file.Project, file.Environment)
+		if file.Created {
+			target += ", new"
+		}
+		fmt.Fprintf(w, "  %-40s -> %-28s %s\n", file.Source, target, strings.Join(counts, ", "))
 	}
 
-	var newKeys []domain.APIKey
-	for k, v := range envMap {
-		if err := domain.ValidateKeyName(k); err != nil {
-			fmt.Printf("Warning: Skipping invalid key '%s': %v\n", k, err)
+	if len(result.Skipped) > 0 {
+		fmt.Fprintf(w, "\n  Skipped:\n")
+		for _, s := range result.Skipped {
+			fmt.Fprintf(w, "    %s: %s\n", s.Path, s.Reason)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// importBatch is a set of keys bound for one project. Layout is set for
+// dotenv files.
+type importBatch struct {
+	source  string
+	project string
+	env     string
+	keys    []service.ImportedKey
+	invalid []string
+	layout  []domain.LayoutLine
+}
+
+// newImportBatch sorts the keys of doc into valid and invalid ones, warning
+// about the latter
+func newImportBatch(source, project, env string, doc service.ImportDocument) importBatch {
+	batch := importBatch{source: source, project: project, env: env, layout: doc.Layout}
+	for _, k := range doc.Keys {
+		if err := domain.ValidateKeyName(k.Key); err != nil {
+			infof("Warning: Skipping invalid key '%s': %v\n", k.Key, err)
+			batch.invalid = append(batch.invalid, k.Key)
 			continue
 		}
+		batch.keys = append(batch.keys, k)
+	}
+	return batch
+}
+
+// importBatches decides which project every document of an import file goes
+// to. A document named by the file, like a Kubernetes Secret, goes to the
+// project of that name; otherwise the project is taken from -p or asked for.
+func importBatches(source string, docs []service.ImportDocument, opts importOptions) ([]importBatch, error) {
+	total := 0
+	for _, doc := range docs {
+		total += len(doc.Keys)
+	}
+	if total == 0 {
+		return nil, usageErrorf("file is empty or contains no valid keys")
+	}
+	if len(docs) > 1 && opts.project != "" {
+		return nil, usageErrorf("%s holds %d services or secrets, each imported into the project of the same name; leave out -p", source, len(docs))
+	}
+
+	var batches []importBatch
+	for _, doc := range docs {
+		target := opts
+		if target.project == "" {
+			target.project = doc.Name
+		}
 
-		newKeys = append(newKeys, domain.APIKey{
-			Title: k,
-			Key:   k,
-			Current: domain.SecretVersion{
-				Value:     v,
-				CreatedAt: time.Now(),
-				CreatedBy: "cli-import",
-			},
-			History: make([]domain.SecretVersion, 0),
-		})
+		project, env, err := importTarget(target)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, newImportBatch(source, project, env, doc))
+	}
+	return batches, nil
+}
+
+// applyImport imports batch into its project, creating the project if
+// needed. Without a strategy an existing project is replaced after asking.
+func applyImport(projects *[]domain.Project, batch importBatch, strategy string) (importResult, error) {
+	project, _ := findProject(*projects, batch.project, batch.env)
+	created := project == nil
+	if created {
+		*projects = append(*projects, domain.Project{Name: batch.project, Environment: batch.env, Keys: []domain.APIKey{}})
+		project = &(*projects)[len(*projects)-1]
+	} else if strategy == "" {
+		ok, err := confirm(fmt.Sprintf("Project '%s' (%s) already exists. Overwrite? [y/N]: ", project.Name, batch.env))
+		if err != nil {
+			return importResult{}, err
+		}
+		if !ok {
+			return importResult{}, withContext(fmt.Errorf("import %w", ErrCancelled), project.Name, batch.env, "")
+		}
+		strategy = service.ImportReplace
+	}
+	if strategy == "" {
+		strategy = service.ImportMerge
+	}
+
+	summary, err := service.ImportKeys(project, batch.keys, strategy, "cli-import", time.Now())
+	if err != nil {
+		return importResult{}, withContext(err, project.Name, batch.env, "")
+	}
+	if batch.layout != nil {
+		service.ApplyDotenvLayout(project, batch.keys, batch.layout, strategy, summary)
+	}
+	summary.Skipped = append(summary.Skipped, batch.invalid...)
+
+	return importResult{
+		Source:        batch.source,
+		Project:       project.Name,
+		Environment:   batch.env,
+		Created:       created,
+		Strategy:      strategy,
+		Imported:      len(summary.Added) + len(summary.Updated),
+		ImportSummary: summary,
+	}, nil
+}
+
+// unlockOrCreateVault unlocks the vault, creating it first if this is the
+// first run
+func unlockOrCreateVault() ([]domain.Project, []byte, error) {
+	firstRun, err := storage.IsFirstRun()
+	if err != nil {
+		return nil, nil, vaultError("failed to check vault status", err)
 	}
 
-	newProject := domain.Project{
-		Name:        name,
-		Environment: env,
-		Keys:        newKeys,
+	var password string
+	if firstRun {
+		infof("No vault found. Creating new vault...\n")
+		password, err = auth.PromptNewPassword()
+		if err != nil {
+			return nil, nil, &CommandError{Code: ExitUsage, Err: err}
+		}
+
+		if err := storage.Initialize(password); err != nil {
+			return nil, nil, vaultError("failed to initialize vault", err)
+		}
+	} else {
+		password, err = auth.PromptPassword("Enter master password: ")
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
-	projects = append(projects, newProject)
+	projects, key, err := storage.Load(password)
+	if err != nil {
+		return nil, nil, vaultError("failed to load vault", err)
+	}
+	return projects, key, nil
+}
 
-	if err := storage.Save(projects, key); err != nil {
-		fmt.Printf("Error saving to vault: %v\n", err)
+// printImportResults prints one result as an object and several, from a
+// file holding several services or secrets, as a list
+func printImportResults(source string, results []importResult) {
+	human := func() {
+		for _, result := range results {
+			infof("Imported '%s' into project '%s' (%s) with strategy %s:\n", source, result.Project, result.Environment, result.Strategy)
+			printImportSummary(result.ImportSummary)
+		}
+	}
+	if len(results) == 1 {
+		printResult(results[0], human)
 		return
 	}
+	printResult(struct {
+		Projects []importResult `json:"projects"`
+	}{results}, human)
+}
+
+// importTarget returns the project and environment to import into, asking
+// for them when no project was given
+func importTarget(opts importOptions) (string, string, error) {
+	name, env := opts.project, opts.env
+	if name == "" {
+		var err error
+		name, err = auth.PromptText("Enter Project Name: ")
+		if err != nil {
+			return "", "", err
+		}
+		name = strings.TrimSpace(name)
+
+		if env == "" {
+			env, err = auth.PromptText("Environment (prod/dev/stage) [dev]: ")
+			if err != nil {
+				return "", "", err
+			}
+			env = strings.TrimSpace(env)
+		}
+	}
+	if env == "" {
+		env = domain.EnvDev
+	}
 
-	fmt.Printf("\nSuccess! Imported project '%s' (%s) with %d keys.\n", name, env, len(newKeys))
-	fmt.Println("Run 'envy' to view your keys in the TUI.")
+	if err := domain.ValidateProjectName(name); err != nil {
+		return "", "", err
+	}
+	if err := domain.ValidateEnvironment(env); err != nil {
+		return "", "", err
+	}
+	return name, env, nil
+}
+
+func printImportSummary(summary service.ImportSummary) {
+	rows := []struct {
+		label string
+		keys  []string
+	}{
+		{"added", summary.Added},
+		{"updated", summary.Updated},
+		{"unchanged", summary.Unchanged},
+		{"skipped", summary.Skipped},
+		{"removed", summary.Removed},
+	}
+	for _, row := range rows {
+		if row.label == "removed" && len(row.keys) == 0 {
+			continue
+		}
+		infof("  %-10s %3d  %s\n", row.label, len(row.keys), strings.Join(row.keys, ", "))
+	}
 }
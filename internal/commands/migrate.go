@@ -0,0 +1,268 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"envy/internal/domain"
+	"envy/internal/service"
+
+	"github.com/spf13/cobra"
+)
+
+type migrateResult struct {
+	Source      string                `json:"source"`
+	Environment string                `json:"environment"`
+	Plan        service.MigrationPlan `json:"plan"`
+	Imported    []importResult        `json:"imported,omitempty"`
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate <source> [file|dir]",
+	Short: "Import secrets from another password manager's export",
+	Long: `Import the items of another password manager into Envy. Every folder,
+group or vault becomes a project, and every item field becomes a key.
+
+Sources:
+  bitwarden  unencrypted JSON export (bw export --format json)
+  keepass    KeePass 2 XML export
+  1password  .1pux export, or a CSV export
+  pass       a password-store directory, decrypted with gpg
+             (default $PASSWORD_STORE_DIR or ~/.password-store)
+
+By default an item's password becomes {item}, its username {item}_USERNAME
+and custom fields {item}_{field}, where {item} and {field} are the names in
+upper case, like GITHUB_TOKEN. URLs, notes and one-time password seeds are
+left out. Items outside any folder go to the project given with -p.
+
+A mapping file (YAML or JSON) changes this:
+
+  projects:              # folder -> project, "" skips the folder
+    Work/Backend: backend
+    Personal: ""
+  default_project: misc  # items outside any folder
+  keys:                  # field -> key template, "" drops the field
+    username: "{item}_USER"
+    url: "{item}_URL"
+  items:                 # per item, by name
+    GitHub:
+      password: GITHUB_TOKEN
+      "*": ""
+
+The plan is shown before anything is written. --preview only shows it.
+
+Examples:
+  envy migrate bitwarden bitwarden_export.json --preview
+  envy migrate keepass vault.xml -e dev --mapping mapping.yaml
+  envy migrate 1password export.1pux -p misc --yes
+  envy migrate pass --strategy skip-existing`,
+	Args:      usageArgs(cobra.RangeArgs(1, 2)),
+	RunE:      runMigrateCommand,
+	ValidArgs: service.MigrationSources,
+}
+
+func init() {
+	RootCmd.AddCommand(migrateCmd)
+	migrateCmd.Flags().StringP("env", "e", domain.EnvDev, "Environment of the projects (dev, stage, prod)")
+	migrateCmd.Flags().StringP("project", "p", "", "Project for items outside any folder")
+	migrateCmd.Flags().String("mapping", "", "Mapping file choosing projects and key names")
+	migrateCmd.Flags().String("strategy", service.ImportMerge, "How to treat existing keys: "+strings.Join(service.ImportStrategies, ", "))
+	migrateCmd.Flags().Bool("preview", false, "Only show what would be imported")
+	migrateCmd.Flags().BoolP("yes", "y", false, "Import without asking for confirmation")
+	migrateCmd.RegisterFlagCompletionFunc("env", completeEnvironments)
+	migrateCmd.RegisterFlagCompletionFunc("project", completeProjectFlag)
+	migrateCmd.RegisterFlagCompletionFunc("strategy", cobra.FixedCompletions(service.ImportStrategies, cobra.ShellCompDirectiveNoFileComp))
+}
+
+func runMigrateCommand(cmd *cobra.Command, args []string) error {
+	source := args[0]
+	env, _ := cmd.Flags().GetString("env")
+	defaultProject, _ := cmd.Flags().GetString("project")
+	mappingPath, _ := cmd.Flags().GetString("mapping")
+	strategy, _ := cmd.Flags().GetString("strategy")
+	preview, _ := cmd.Flags().GetBool("preview")
+	yes, _ := cmd.Flags().GetBool("yes")
+
+	if !slices.Contains(service.MigrationSources, source) {
+		return usageErrorf("unknown source '%s' (must be one of %s)", source, strings.Join(service.MigrationSources, ", "))
+	}
+	if !slices.Contains(service.ImportStrategies, strategy) {
+		return usageErrorf("invalid --strategy '%s' (must be one of %s)", strategy, strings.Join(service.ImportStrategies, ", "))
+	}
+	if err := domain.ValidateEnvironment(env); err != nil {
+		return err
+	}
+
+	mapping := service.DefaultMigrationMapping()
+	if mappingPath != "" {
+		data, err := readInput(mappingPath)
+		if err != nil {
+			return err
+		}
+		if mapping, err = service.ParseMigrationMapping(data); err != nil {
+			return usageErrorf("invalid mapping file %s: %v", mappingPath, err)
+		}
+	}
+	if defaultProject != "" {
+		mapping.DefaultProject = defaultProject
+	}
+
+	items, err := readForeignItems(source, args[1:])
+	if err != nil {
+		return err
+	}
+
+	plan := service.PlanMigration(items, mapping)
+	if len(plan.Projects) == 0 {
+		return usageErrorf("nothing to import from %d items (check the mapping, or use -p for items outside any folder)", len(items))
+	}
+	result := migrateResult{Source: source, Environment: env, Plan: plan}
+
+	if preview {
+		printResult(result, func() { printMigrationPlan(os.Stdout, result) })
+		return nil
+	}
+
+	if !yes {
+		printMigrationPlan(os.Stderr, result)
+		ok, err := confirm(fmt.Sprintf("Import %d keys into %d projects? [y/N]: ", countMigratedKeys(plan), len(plan.Projects)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("migration %w", ErrCancelled)
+		}
+	}
+
+	projects, key, err := unlockOrCreateVault()
+	if err != nil {
+		return err
+	}
+
+	for _, p := range plan.Projects {
+		if err := domain.ValidateProjectName(p.Name); err != nil {
+			return err
+		}
+		batch := importBatch{project: p.Name, env: env}
+		for _, k := range p.Keys {
+			if err := domain.ValidateKeyName(k.Key); err != nil {
+				infof("Warning: Skipping invalid key '%s': %v\n", k.Key, err)
+				batch.invalid = append(batch.invalid, k.Key)
+				continue
+			}
+			batch.keys = append(batch.keys, service.ImportedKey{Key: k.Key, Value: k.Value})
+		}
+
+		imported, err := applyImport(&projects, batch, strategy)
+		if err != nil {
+			return err
+		}
+		result.Imported = append(result.Imported, imported)
+	}
+
+	if err := saveVault(projects, key); err != nil {
+		return err
+	}
+
+	printResult(result, func() {
+		for _, imported := range result.Imported {
+			infof("Migrated %s into project '%s' (%s):\n", source, imported.Project, imported.Environment)
+			printImportSummary(imported.ImportSummary)
+		}
+	})
+	return nil
+}
+
+// readForeignItems reads the export of source at the path in args
+func readForeignItems(source string, args []string) ([]service.ForeignItem, error) {
+	if source == "pass" {
+		dir := os.Getenv("PASSWORD_STORE_DIR")
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		if dir == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			dir = filepath.Join(home, ".password-store")
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return nil, usageErrorf("%s is not a password-store directory", dir)
+		}
+
+		items, err := service.ReadPassStore(dir, gpgDecrypt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read password store: %w", err)
+		}
+		return items, nil
+	}
+
+	if len(args) == 0 {
+		return nil, usageErrorf("%s needs the path of an export file", source)
+	}
+	data, err := readInput(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var items []service.ForeignItem
+	switch source {
+	case "bitwarden":
+		items, err = service.ReadBitwarden(data)
+	case "keepass":
+		items, err = service.ReadKeePass(data)
+	case "1password":
+		items, err = service.ReadOnePassword(data)
+	}
+	if err != nil {
+		return nil, &CommandError{Code: ExitUsage, Err: err}
+	}
+	return items, nil
+}
+
+// gpgDecrypt decrypts a password-store entry with the local gpg, which asks
+// for the key's passphrase through its agent if needed
+func gpgDecrypt(path string) ([]byte, error) {
+	cmd := exec.Command("gpg", "--quiet", "--yes", "--decrypt", path)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, errors.New("gpg is needed to read a password store but wasn't found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gpg failed to decrypt %s: %w", path, err)
+	}
+	return out, nil
+}
+
+func printMigrationPlan(w io.Writer, result migrateResult) {
+	fmt.Fprintf(w, "Migration plan from %s:\n", result.Source)
+	for _, p := range result.Plan.Projects {
+		fmt.Fprintf(w, "\n  %s (%s), %d keys\n", p.Name, result.Environment, len(p.Keys))
+		for _, k := range p.Keys {
+			fmt.Fprintf(w, "    %-30s <- %-35s %s\n", k.Key, k.Item+" › "+k.Field, domain.MaskValue(k.Value))
+		}
+	}
+	if len(result.Plan.Skipped) > 0 {
+		fmt.Fprintf(w, "\n  Skipped: %s\n", strings.Join(result.Plan.Skipped, ", "))
+	}
+	for _, warning := range result.Plan.Warnings {
+		fmt.Fprintf(w, "  Warning: %s\n", warning)
+	}
+	fmt.Fprintln(w)
+}
+
+func countMigratedKeys(plan service.MigrationPlan) int {
+	n := 0
+	for _, p := range plan.Projects {
+		n += len(p.Keys)
+	}
+	return n
+}
@@ -0,0 +1,193 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"envy/internal/domain"
+	"envy/internal/service"
+	"envy/internal/storage"
+
+	"github.com/spf13/cobra"
+)
+
+// Exit codes returned by every envy command. Keep in sync with the
+// Exit Codes table in docs/reference/commands.md.
+const (
+	ExitOK        = 0
+	ExitFailure   = 1  // unclassified error
+	ExitUsage     = 2  // invalid arguments, flags or input values
+	ExitAuth      = 3  // wrong master password
+	ExitNotFound  = 4  // project, key or version doesn't exist
+	ExitVault     = 5  // vault missing, unreadable or not writable
+	ExitCancelled = 6  // user declined a confirmation prompt
+	ExitDrift     = 10 // 'envy diff --exit-code' found differences
+
+	// 'envy run' follows the shell: 126 and 127 when the command can't be
+	// started, the command's own status otherwise (128+N if killed by signal N)
+	ExitCommandNotRunnable = 126
+	ExitCommandNotFound    = 127
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// outputFormat is set by the global --output flag
+var outputFormat = outputText
+
+// ErrCancelled is returned when the user declines a confirmation prompt
+var ErrCancelled = errors.New("operation cancelled")
+
+// CommandError is an error with an exit code and the project/key it concerns.
+// Commands return it (or plain errors, which are classified by exitCodeFor)
+// and Execute turns it into the process exit status.
+type CommandError struct {
+	Code        int
+	Err         error
+	Project     string
+	Environment string
+	Key         string
+
+	// quiet errors only set the exit status, e.g. a child process that failed
+	// and already reported why on its own stderr
+	quiet bool
+}
+
+func (e *CommandError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+func usageErrorf(format string, args ...any) error {
+	return &CommandError{Code: ExitUsage, Err: fmt.Errorf(format, args...)}
+}
+
+// vaultError wraps a failure from loading or saving the vault. Wrong passwords
+// keep their own exit code.
+func vaultError(action string, err error) error {
+	code := ExitVault
+	if errors.Is(err, storage.ErrIncorrectPassword) {
+		code = ExitAuth
+	}
+	return &CommandError{Code: code, Err: fmt.Errorf("%s: %w", action, err)}
+}
+
+// withContext attaches project, environment and key names to err without
+// changing its exit code.
+func withContext(err error, project, env, key string) error {
+	if err == nil {
+		return nil
+	}
+	return &CommandError{
+		Code:        exitCodeFor(err),
+		Err:         err,
+		Project:     project,
+		Environment: env,
+		Key:         key,
+	}
+}
+
+// usageArgs wraps a cobra argument validator so its errors exit with ExitUsage
+func usageArgs(validate cobra.PositionalArgs) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := validate(cmd, args); err != nil {
+			return &CommandError{Code: ExitUsage, Err: err}
+		}
+		return nil
+	}
+}
+
+func exitCodeFor(err error) int {
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code != ExitOK {
+		return cmdErr.Code
+	}
+
+	var validationErr *domain.ValidationError
+	var ambiguousErr *service.AmbiguousProjectError
+	switch {
+	case errors.Is(err, storage.ErrIncorrectPassword):
+		return ExitAuth
+	case errors.Is(err, domain.ErrNotFound):
+		return ExitNotFound
+	case errors.Is(err, ErrCancelled):
+		return ExitCancelled
+	case errors.As(err, &validationErr), errors.As(err, &ambiguousErr):
+		return ExitUsage
+	}
+	return ExitFailure
+}
+
+// ErrorReport is an error as printed with --output json, under "error"
+type ErrorReport struct {
+	Code        int    `json:"code"`
+	Message     string `json:"message"`
+	Project     string `json:"project,omitempty"`
+	Environment string `json:"environment,omitempty"`
+	Key         string `json:"key,omitempty"`
+}
+
+// NewErrorReport classifies err into its exit code and the project,
+// environment and key it concerns. It returns false for quiet errors, which
+// only set the exit status.
+func NewErrorReport(err error) (ErrorReport, bool) {
+	report := ErrorReport{Code: exitCodeFor(err), Message: err.Error()}
+	var cmdErr *CommandError
+	for e := err; errors.As(e, &cmdErr); e = cmdErr.Err {
+		if cmdErr.quiet {
+			return report, false
+		}
+		if report.Project == "" {
+			report.Project, report.Environment = cmdErr.Project, cmdErr.Environment
+		}
+		if report.Key == "" {
+			report.Key = cmdErr.Key
+		}
+	}
+	return report, true
+}
+
+// reportError prints err in the selected output format and returns the exit code
+func reportError(err error) int {
+	report, ok := NewErrorReport(err)
+	if !ok {
+		return report.Code
+	}
+
+	if outputFormat == outputJSON {
+		writeJSON(map[string]ErrorReport{"error": report})
+		return report.Code
+	}
+
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	return report.Code
+}
+
+// printResult writes result to stdout as JSON when --output json is set,
+// otherwise it calls human to print the text form.
+func printResult(result any, human func()) {
+	if outputFormat == outputJSON {
+		writeJSON(result)
+		return
+	}
+	human()
+}
+
+func writeJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// infof prints human-oriented progress messages. They always go to stderr so
+// stdout only carries results.
+func infof(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format, args...)
+}
@@ -0,0 +1,76 @@
+//go:build unix
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// forwardedSignals are relayed from envy to the command started by 'envy run'
+var forwardedSignals = []os.Signal{
+	syscall.SIGINT,
+	syscall.SIGTERM,
+	syscall.SIGHUP,
+	syscall.SIGQUIT,
+	syscall.SIGUSR1,
+	syscall.SIGUSR2,
+}
+
+// sentByTerminal reports whether the child with pid has already received sig
+// from the terminal. Ctrl-C and Ctrl-\ reach the whole foreground process
+// group, so forwarding them to a child in that group would deliver them twice.
+// SIGTERM, SIGHUP and the other signals never come from the keyboard and are
+// always forwarded.
+//
+// Go doesn't say who sent a signal, so a SIGINT or SIGQUIT sent with kill to
+// envy while the child is in the foreground group is taken for the terminal's
+// and not forwarded. Signal the whole group (kill -INT -PGID) or use SIGTERM
+// in that case.
+func sentByTerminal(sig os.Signal, pid int) bool {
+	if sig != syscall.SIGINT && sig != syscall.SIGQUIT {
+		return false
+	}
+
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+
+	foreground, err := unix.IoctlGetInt(int(tty.Fd()), unix.TIOCGPGRP)
+	if err != nil {
+		return false
+	}
+	group, err := unix.Getpgid(pid)
+	return err == nil && group == foreground
+}
+
+// restartSignals are the names accepted by 'envy run --restart-signal'
+var restartSignals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// parseSignal accepts a signal name with or without the SIG prefix
+func parseSignal(name string) (os.Signal, error) {
+	sig, ok := restartSignals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return nil, fmt.Errorf("unknown signal '%s' (use HUP, INT, QUIT, KILL, TERM, USR1 or USR2)", name)
+	}
+	return sig, nil
+}
+
+// execReplace replaces the envy process with the command
+func execReplace(path string, args, env []string) error {
+	return syscall.Exec(path, args, env)
+}
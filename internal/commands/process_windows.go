@@ -0,0 +1,33 @@
+//go:build windows
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// forwardedSignals are relayed from envy to the command started by 'envy run'
+var forwardedSignals = []os.Signal{os.Interrupt}
+
+// sentByTerminal reports whether the child has already received sig from the
+// console. Ctrl-C reaches every process attached to the console.
+func sentByTerminal(sig os.Signal, pid int) bool {
+	return true
+}
+
+// parseSignal accepts the names used on Unix. Windows can't deliver them, so
+// the command is always killed.
+func parseSignal(name string) (os.Signal, error) {
+	switch strings.TrimPrefix(strings.ToUpper(name), "SIG") {
+	case "HUP", "INT", "QUIT", "KILL", "TERM", "USR1", "USR2":
+		return os.Kill, nil
+	}
+	return nil, fmt.Errorf("unknown signal '%s' (use HUP, INT, QUIT, KILL, TERM, USR1 or USR2)", name)
+}
+
+// execReplace replaces the envy process with the command
+func execReplace(path string, args, env []string) error {
+	return usageErrorf("--exec is not supported on Windows")
+}
@@ -0,0 +1,204 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"envy/internal/domain"
+	"envy/internal/service"
+
+	"github.com/spf13/cobra"
+)
+
+type renderResult struct {
+	Project     string   `json:"project"`
+	Environment string   `json:"environment"`
+	Template    string   `json:"template"`
+	Path        string   `json:"path,omitempty"`
+	Secrets     []string `json:"secrets"`
+	Missing     []string `json:"missing,omitempty"`
+}
+
+var renderCmd = &cobra.Command{
+	Use:   "render [template] -p project [-o file]",
+	Short: "Fill a config file template with secrets",
+	Long: `Render a Go text/template with secrets from a project, for apps that read
+YAML, JSON or other config files instead of environment variables.
+
+Template functions:
+  secret "KEY"           value of KEY in the project (references expanded)
+  secret "proj/env/KEY"  value of KEY in another project
+  b64enc                 base64-encode a value
+  json                   encode a value as JSON, e.g. a quoted string
+  required "message"     fail with message if the value is empty
+
+  .Project and .Environment hold the project's name and environment.
+
+The result is printed to stdout, or written to the file given with -o with
+permissions 0600. --check only verifies that every secret the template
+names exists, in every branch, without rendering anything.
+
+Examples:
+  envy render -p myapp -e prod config.yaml.tmpl -o config.yaml
+  envy render -p myapp config.json.tmpl | kubectl create secret generic app --from-file=config.json=/dev/stdin
+  envy render -p myapp -e prod config.yaml.tmpl --check
+
+Template:
+  database:
+    url: {{ secret "DATABASE_URL" | json }}
+    password: {{ secret "DB_PASSWORD" | required "DB_PASSWORD is needed" | json }}
+  sentry: {{ secret "shared/prod/SENTRY_DSN" }}
+  token: {{ secret "TOKEN" | b64enc }}`,
+	Args: usageArgs(cobra.ExactArgs(1)),
+	RunE: runRenderCommand,
+}
+
+func init() {
+	RootCmd.AddCommand(renderCmd)
+	renderCmd.Flags().StringP("project", "p", "", "Project whose secrets fill the template")
+	renderCmd.Flags().StringP("env", "e", "", "Environment of the project (dev, stage, prod)")
+	renderCmd.Flags().StringP("out", "o", "-", "File to write, or - for stdout")
+	renderCmd.Flags().Bool("check", false, "Only check that every secret the template names exists")
+	renderCmd.MarkFlagRequired("project")
+	renderCmd.RegisterFlagCompletionFunc("project", completeProjectFlag)
+	renderCmd.RegisterFlagCompletionFunc("env", completeEnvironments)
+}
+
+func runRenderCommand(cmd *cobra.Command, args []string) error {
+	templatePath := args[0]
+	projectName, _ := cmd.Flags().GetString("project")
+	environment, _ := cmd.Flags().GetString("env")
+	outPath, _ := cmd.Flags().GetString("out")
+	check, _ := cmd.Flags().GetBool("check")
+
+	text, err := readInput(templatePath)
+	if err != nil {
+		return err
+	}
+
+	projects, _, err := unlockVault()
+	if err != nil {
+		return err
+	}
+
+	project, err := resolveProject(projects, projectName, environment)
+	if err != nil {
+		return err
+	}
+
+	resolver := service.NewResolver(projects)
+	tmpl, err := service.ParseTemplate(filepath.Base(templatePath), string(text), resolver, *project)
+	if err != nil {
+		return &CommandError{Code: ExitUsage, Err: fmt.Errorf("invalid template: %w", err)}
+	}
+
+	result := renderResult{
+		Project:     project.Name,
+		Environment: project.Environment,
+		Template:    templatePath,
+		Secrets:     service.TemplateSecrets(tmpl),
+	}
+
+	if check {
+		return checkTemplate(result, resolver, project)
+	}
+
+	var rendered bytes.Buffer
+	data := map[string]string{"Project": project.Name, "Environment": project.Environment}
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return withContext(fmt.Errorf("failed to render template: %w", err), project.Name, project.Environment, "")
+	}
+	warnUnresolved(resolver)
+
+	if outPath == "-" {
+		_, err := os.Stdout.Write(rendered.Bytes())
+		return err
+	}
+
+	if err := writePrivateFile(outPath, rendered.Bytes()); err != nil {
+		return err
+	}
+
+	result.Path = outPath
+	printResult(result, func() {
+		infof("Rendered %s with %d secrets from '%s' (%s) to %s\n",
+			templatePath, len(result.Secrets), project.Name, project.Environment, outPath)
+	})
+	return nil
+}
+
+// checkTemplate reports every secret the template names that can't be resolved
+func checkTemplate(result renderResult, resolver *service.Resolver, project *domain.Project) error {
+	var firstErr error
+	for _, ref := range result.Secrets {
+		if _, err := resolver.Reference(*project, ref); err != nil {
+			result.Missing = append(result.Missing, ref)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	printResult(result, func() {
+		for _, ref := range result.Secrets {
+			status := "ok"
+			for _, missing := range result.Missing {
+				if missing == ref {
+					status = "MISSING"
+				}
+			}
+			fmt.Printf("  %-8s %s\n", status, ref)
+		}
+	})
+
+	if firstErr != nil {
+		err := fmt.Errorf("%d of %d secrets can't be resolved: %w", len(result.Missing), len(result.Secrets), firstErr)
+		return withContext(err, project.Name, project.Environment, "")
+	}
+	return nil
+}
+
+// readInput reads a file, or stdin for "-"
+func readInput(path string) ([]byte, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, &CommandError{Code: ExitUsage, Err: fmt.Errorf("failed to read %s: %w", path, err)}
+	}
+	return data, nil
+}
+
+// writePrivateFile replaces path with data, readable only by the owner. The
+// file is written next to the target and renamed, so readers never see a
+// partial file.
+func writePrivateFile(path string, data []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := temp.Chmod(0o600); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -9,9 +9,12 @@ package commands
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"envy/internal/auth"
 	"envy/internal/config"
+	"envy/internal/domain"
+	"envy/internal/service"
 	"envy/internal/storage"
 	"envy/internal/tui"
 
@@ -22,6 +25,8 @@ import (
 var (
 	importFile  string
 	exportProj  string
+	exportEnv   string
+	exportOpts  exportOptions
 	showVersion bool
 )
 
@@ -34,34 +39,48 @@ var RootCmd = &cobra.Command{
 
 Quick shortcuts:
   envy -i file.env          Import .env file into vault
-  envy -t project           Export project to .env file
+  envy -t project [-e env]  Export project to .env file
   envy -s project KEY=VAL   Set a secret (alias for 'envy set')
 
 For more options, use subcommands:
   envy set project KEY=VALUE -e prod
   envy run project -- command`,
 
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	Args: usageArgs(cobra.NoArgs),
+
+	SilenceErrors: true,
+	SilenceUsage:  true,
+
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if outputFormat != outputText && outputFormat != outputJSON {
+			return usageErrorf("invalid --output '%s' (must be text or json)", outputFormat)
+		}
+
 		appConfig = config.LoadAppConfig()
 		storage.SetConfig(appConfig.Backend)
 
+		if env := appConfig.Defaults.Environment; env != "" {
+			if err := domain.ValidateEnvironment(env); err != nil {
+				return usageErrorf("config defaults.environment: %v", err)
+			}
+		}
+
 		if err := config.EnsureDataDir(appConfig.Backend); err != nil {
-			fmt.Printf("Warning: failed to create data directory: %v\n", err)
+			infof("Warning: failed to create data directory: %v\n", err)
 		}
+		return nil
 	},
 
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if importFile != "" {
-			RunImport(importFile)
-			return
+			return RunImport(importFile, importOptions{})
 		}
 
 		if exportProj != "" {
-			RunExport(exportProj)
-			return
+			return RunExport(exportProj, exportEnv, exportOpts)
 		}
 
-		runTUI()
+		return runTUI()
 	},
 }
 
@@ -70,6 +89,22 @@ func init() {
 	RootCmd.Flags().StringVarP(&importFile, "import", "i", "", "Import .env file into vault")
 	RootCmd.Flags().StringVarP(&exportProj, "export", "t", "", "Export project to .env file")
 	RootCmd.Flags().BoolVar(&showVersion, "version", false, "Show version information")
+	RootCmd.Flags().StringVarP(&exportEnv, "env", "e", "", "Environment of the project to export (dev, stage, prod)")
+	RootCmd.Flags().BoolVar(&exportOpts.raw, "raw", false, "Export values as stored, without expanding ${...} references")
+	RootCmd.Flags().StringVar(&exportOpts.format, "format", "dotenv", "Export format: "+strings.Join(service.ExportFormats, ", "))
+	RootCmd.Flags().StringVarP(&exportOpts.out, "out", "o", "", "Export to this file instead of the format's default name, or - for stdout")
+	RootCmd.Flags().BoolVar(&exportOpts.force, "force", false, "Overwrite an existing export file without asking")
+	RootCmd.Flags().BoolVar(&exportOpts.merge, "merge", false, "Update only the vault's keys in an existing .env file, keeping everything else")
+	RootCmd.Flags().BoolVar(&exportOpts.gitignoreCheck, "gitignore-check", false, "Warn if the export file isn't ignored by git")
+	RootCmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(service.ExportFormats, cobra.ShellCompDirectiveNoFileComp))
+	RootCmd.RegisterFlagCompletionFunc("export", completeProjectFlag)
+	RootCmd.RegisterFlagCompletionFunc("env", completeEnvironments)
+
+	RootCmd.PersistentFlags().StringVar(&outputFormat, "output", outputText, "Output format for results and errors: text or json")
+
+	RootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &CommandError{Code: ExitUsage, Err: err}
+	})
 }
 
 // Execute is the main entry point for the CLI.
@@ -87,10 +122,7 @@ func Execute() {
 	}
 
 	if err := RootCmd.Execute(); err != nil {
-		if err.Error() != "pflag: help requested" {
-			fmt.Println(err)
-			os.Exit(1)
-		}
+		os.Exit(reportError(err))
 	}
 }
 
@@ -152,51 +184,44 @@ func isFlag(arg string) bool {
 	return len(arg) > 0 && arg[0] == '-'
 }
 
-func runTUI() {
+func runTUI() error {
 	firstRun, err := storage.IsFirstRun()
 	if err != nil {
-		fmt.Printf("Error checking vault status: %v\n", err)
-		os.Exit(1)
+		return vaultError("failed to check vault status", err)
 	}
 
 	var password string
 
 	if firstRun {
-		fmt.Println("Welcome to Envy - Secure Secret Manager")
-		fmt.Println("No vault found. Let's create one!")
-		fmt.Println("")
+		infof("Welcome to Envy - Secure Secret Manager\n")
+		infof("No vault found. Let's create one!\n\n")
 
 		password, err = auth.PromptNewPassword()
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			return &CommandError{Code: ExitUsage, Err: err}
 		}
 
 		if err := storage.Initialize(password); err != nil {
-			fmt.Printf("Error initializing vault: %v\n", err)
-			os.Exit(1)
+			return vaultError("failed to initialize vault", err)
 		}
 
-		fmt.Println("Vault created successfully!")
-		fmt.Println("")
+		infof("Vault created successfully!\n\n")
 	} else {
 		password, err = auth.PromptPassword("Enter master password: ")
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			return err
 		}
 	}
 
 	projects, key, err := storage.Load(password)
 	if err != nil {
-		fmt.Printf("Error loading vault: %v\n", err)
-		os.Exit(1)
+		return vaultError("failed to load vault", err)
 	}
 
 	p := tea.NewProgram(tui.NewModel(projects, key, appConfig), tea.WithAltScreen())
 
 	if _, err := p.Run(); err != nil {
-		fmt.Printf("Error running TUI: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to run TUI: %w", err)
 	}
+	return nil
 }
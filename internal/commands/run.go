@@ -1,23 +1,28 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
-	"envy/internal/auth"
 	"envy/internal/config"
 	"envy/internal/domain"
+	"envy/internal/service"
 	"envy/internal/storage"
 
 	"github.com/spf13/cobra"
 )
 
 var runCmd = &cobra.Command{
-	Use:                "run [project] -- [command]",
-	Short:              "Run a command with secrets injected as environment variables",
-	DisableFlagParsing: true, // disabled to handle flag manually
+	Use:   "run [project[:env]]... -- [command]",
+	Short: "Run a command with secrets injected as environment variables",
 	Long: `Run a command with project secrets loaded into the environment.
 
 This command fetches all secrets from the specified project and executes
@@ -26,130 +31,608 @@ The secrets are isolated to the subprocess and do not affect your shell.
 
 Examples:
   envy run myproject -- npm start
-  envy run production -- python app.py
+  envy run myproject -e prod -- python app.py
   envy run dev -- make build
   envy run staging -- docker-compose up
 
-The secrets are only available to the child process and are cleaned up
-when the process exits.`,
-	RunE: runWithSecrets,
+If the project exists in several environments, choose one with -e/--env
+or set defaults.environment in config.lua.
+
+Layers:
+  Several projects can be injected at once. They are applied left to right
+  and later layers win, so put shared settings first:
+
+    envy run base:prod myapp:prod -- ./server
+
+  A layer without ':env' uses -e/--env. Keys overridden with a different
+  value are reported on stderr; --explain lists where every variable came from.
+
+Selecting and renaming keys:
+  --only API_KEY,DB_*        inject only keys matching these patterns
+  --exclude 'DEBUG_*'        skip keys matching these patterns
+  --strip-prefix MYAPP_      remove a prefix from stored names
+  --prefix APP_              add a prefix to injected names
+  --map DB_URL=DATABASE_URL  inject a key under another name
+
+  Patterns match stored key names. A mapped key keeps its mapped name, other
+  keys have the prefix stripped, then added. The same rules can be set per
+  project in config.lua (projects["name"].inject); flags override them.
+
+Signals and exit status:
+  SIGINT, SIGTERM, SIGHUP, SIGQUIT, SIGUSR1 and SIGUSR2 sent to envy are
+  forwarded to the command. envy exits with the command's exit code, or
+  128+N when the command was killed by signal N.
+
+  With --exec, envy replaces itself with the command instead of waiting for
+  it, so no process holding the secrets stays behind:
+
+    envy run myapp --exec -- ./server
+
+Masking output:
+  With --mask the command's stdout and stderr are filtered and every injected
+  value, also base64 or URL-encoded, is replaced with ***KEY***. Values
+  shorter than 4 characters are left alone.
+
+Environment:
+  The command inherits envy's environment plus the secrets. A secret
+  replaces an inherited variable of the same name with a warning; with
+  --no-override that is an error instead. --clean-env starts from an empty
+  environment, keeping only the variables named with --keep:
+
+    envy run myapp --clean-env --keep PATH,HOME,'LC_*' -- ./server
+
+  --dry-run prints the environment the command would get, secrets masked,
+  without running anything. The command after '--' is optional then.
+
+Secrets as files:
+  Tools that read credentials from a file path can get one with --as-file.
+  The value is written to a 0600 file in a private directory (under
+  $XDG_RUNTIME_DIR or /dev/shm where available) and the variable holds
+  the path instead:
+
+    envy run gcp --as-file GOOGLE_APPLICATION_CREDENTIALS -- gcloud auth list
+
+  The files are overwritten and removed when the command exits. Files left
+  behind by a run that was killed are removed by the next 'envy run'.
+
+References:
+  Values may refer to other keys, like ${DB_USER} or
+  ${ref:shared/prod/SENTRY_DSN}; they are expanded before injecting. Use
+  --raw to inject values exactly as stored.
+
+Restarting on changes:
+  With --watch, envy checks the vault file for saves (from the TUI or
+  'envy set') and restarts the command when the values it gets changed.
+  The command is sent --restart-signal (TERM by default) and killed if it
+  hasn't exited after --grace (10s). When the command exits by itself, so
+  does envy.
+
+    envy run myapp --watch -- npm run dev
+
+Everything after '--' is passed to the command untouched. The secrets are
+only available to the child process and are cleaned up when the process exits.`,
+	RunE:              runWithSecrets,
+	ValidArgsFunction: completeRunArgs,
 }
 
 func init() {
 	RootCmd.AddCommand(runCmd)
+	runCmd.Flags().StringP("env", "e", "", "Environment of the project (dev, stage, prod)")
+	runCmd.Flags().Bool("explain", false, "Print which layer each variable came from (values masked)")
+	runCmd.Flags().StringSlice("only", nil, "Inject only keys matching these patterns")
+	runCmd.Flags().StringSlice("exclude", nil, "Skip keys matching these patterns")
+	runCmd.Flags().String("strip-prefix", "", "Remove this prefix from stored key names")
+	runCmd.Flags().String("prefix", "", "Add this prefix to injected variable names")
+	runCmd.Flags().StringToString("map", nil, "Inject a key under another name (STORED=INJECTED)")
+	runCmd.Flags().Bool("exec", false, "Replace envy with the command instead of running it as a child")
+	runCmd.Flags().Bool("mask", false, "Replace secret values in the command's output with ***KEY***")
+	runCmd.Flags().Bool("clean-env", false, "Start the command with an empty environment")
+	runCmd.Flags().StringSlice("keep", nil, "Variables to keep with --clean-env (patterns allowed)")
+	runCmd.Flags().Bool("no-override", false, "Fail if a secret would replace an inherited variable")
+	runCmd.Flags().Bool("raw", false, "Inject values as stored, without expanding ${...} references")
+	runCmd.Flags().StringSlice("as-file", nil, "Pass these variables as paths to temporary files holding the values")
+	runCmd.Flags().Bool("watch", false, "Restart the command when a save to the vault changes its secrets")
+	runCmd.Flags().String("restart-signal", "TERM", "Signal sent to stop the command before a --watch restart")
+	runCmd.Flags().Duration("grace", 10*time.Second, "How long to wait for the command to stop before killing it")
+	runCmd.Flags().Bool("dry-run", false, "Print the environment the command would get (secrets masked) and exit")
+	runCmd.RegisterFlagCompletionFunc("env", completeEnvironments)
+}
+
+// runEnvVar is a variable listed by 'envy run --dry-run'
+type runEnvVar struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+type runDryRunResult struct {
+	Command     []string    `json:"command,omitempty"`
+	Environment []runEnvVar `json:"environment"`
 }
 
 func runWithSecrets(cmd *cobra.Command, args []string) error {
-	// Flag parsing is disabled, so help command is handled manually
-	for _, arg := range args {
-		if arg == "-h" || arg == "--help" {
-			cmd.Help()
-			return nil
+	separatorIndex := cmd.ArgsLenAtDash()
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	layerSpecs, commandArgs := args, []string(nil)
+	if separatorIndex != -1 {
+		layerSpecs, commandArgs = args[:separatorIndex], args[separatorIndex:]
+	}
+
+	if separatorIndex == -1 && !dryRun {
+		return usageErrorf("missing '--' separator\n\nUsage: envy run [project] -- [command]\n\nExamples:\n  envy run myproject -- npm start\n  envy run production -- python app.py")
+	}
+
+	if len(layerSpecs) == 0 {
+		return usageErrorf("missing project name before '--'\n\nUsage: envy run [project] -- [command]")
+	}
+
+	if len(commandArgs) == 0 && !dryRun {
+		return usageErrorf("missing command after '--'\n\nUsage: envy run [project] -- [command]")
+	}
+
+	opts := runOptions{commandArgs: commandArgs}
+	opts.explain, _ = cmd.Flags().GetBool("explain")
+	opts.mask, _ = cmd.Flags().GetBool("mask")
+	opts.noOverride, _ = cmd.Flags().GetBool("no-override")
+	opts.raw, _ = cmd.Flags().GetBool("raw")
+	opts.asFiles, _ = cmd.Flags().GetStringSlice("as-file")
+	environment, _ := cmd.Flags().GetString("env")
+	replace, _ := cmd.Flags().GetBool("exec")
+	cleanEnv, _ := cmd.Flags().GetBool("clean-env")
+	keep, _ := cmd.Flags().GetStringSlice("keep")
+	watch, _ := cmd.Flags().GetBool("watch")
+	if replace && opts.mask {
+		return usageErrorf("--mask can't be used with --exec, envy must stay running to filter output")
+	}
+	if replace && len(opts.asFiles) > 0 {
+		return usageErrorf("--as-file can't be used with --exec, envy must stay running to remove the files")
+	}
+	if watch && (replace || dryRun) {
+		return usageErrorf("--watch can't be used with --exec or --dry-run")
+	}
+	if len(keep) > 0 && !cleanEnv {
+		return usageErrorf("--keep only applies together with --clean-env")
+	}
+
+	var restart watchOptions
+	if watch {
+		var err error
+		if restart, err = watchOptionsFromFlags(cmd); err != nil {
+			return err
 		}
 	}
 
-	separatorIndex := -1
-	for i, arg := range args {
-		if arg == "--" {
-			separatorIndex = i
-			break
+	opts.inherited = os.Environ()
+	if cleanEnv {
+		var err error
+		if opts.inherited, err = service.KeepEnvironment(opts.inherited, keep); err != nil {
+			return &CommandError{Code: ExitUsage, Err: err}
 		}
 	}
 
-	if separatorIndex == -1 {
-		return fmt.Errorf("missing '--' separator\n\nUsage: envy run [project] -- [command]\n\nExamples:\n  envy run myproject -- npm start\n  envy run production -- python app.py")
+	projects, key, err := unlockVault()
+	if err != nil {
+		return err
+	}
+
+	opts.layerSpecs, err = resolveLayerSpecs(projects, layerSpecs, environment)
+	if err != nil {
+		return err
 	}
 
-	if separatorIndex == 0 {
-		return fmt.Errorf("missing project name before '--'\n\nUsage: envy run [project] -- [command]")
+	storage.SweepSecretDirs()
+	setup, err := prepareRun(cmd, opts, projects, dryRun)
+	if err != nil {
+		return err
 	}
+	defer setup.cleanup()
 
-	if separatorIndex >= len(args)-1 {
-		return fmt.Errorf("missing command after '--'\n\nUsage: envy run [project] -- [command]")
+	if err := setup.report(opts); err != nil {
+		return err
 	}
 
-	projectName := args[0]
-	commandArgs := args[separatorIndex+1:]
+	if dryRun {
+		return printDryRun(commandArgs, setup.env, setup.injected, setup.layers)
+	}
 
-	appConfig := config.LoadAppConfig()
-	storage.SetConfig(appConfig.Backend)
+	infof("Running: %s\n\n", formatCommand(commandArgs))
 
-	if err := config.EnsureDirectories(); err != nil {
-		return fmt.Errorf("failed to create directories: %w", err)
+	if replace {
+		return replaceWithCommand(commandArgs, setup.env)
+	}
+	if watch {
+		return watchCommand(cmd, opts, restart, key, setup)
 	}
 
-	firstRun, err := storage.IsFirstRun()
+	stdout, stderr, flush := setup.writers(opts.mask)
+	defer flush()
+	return executeCommand(commandArgs, setup.env, stdout, stderr)
+}
+
+// runOptions holds what 'envy run' needs to prepare the command's environment
+type runOptions struct {
+	// layerSpecs are the layers as "name:env", resolved once so a reload
+	// picks the same projects
+	layerSpecs  []string
+	commandArgs []string
+	inherited   []string
+	asFiles     []string
+	explain     bool
+	mask        bool
+	noOverride  bool
+	raw         bool
+}
+
+// runSetup is the environment prepared for one start of the command
+type runSetup struct {
+	layers     []domain.Project
+	injected   []service.LayeredValue
+	env        []string
+	collisions []service.EnvCollision
+	// unresolved lists the references left as they are in the values
+	unresolved []string
+
+	// secrets holds the injected values, before --as-file replaced them with paths
+	secrets   map[string]string
+	secretDir *storage.SecretDir
+}
+
+// prepareRun turns the layers into the environment for the command. With
+// dryRun no --as-file files are written.
+func prepareRun(cmd *cobra.Command, opts runOptions, projects []domain.Project, dryRun bool) (*runSetup, error) {
+	layers, err := resolveLayers(projects, opts.layerSpecs, "")
 	if err != nil {
-		return fmt.Errorf("failed to check vault status: %w", err)
+		return nil, err
+	}
+
+	resolver := service.NewResolver(projects)
+	for i, layer := range layers {
+		if !opts.raw {
+			resolved, err := resolver.Project(layer)
+			if err != nil {
+				return nil, withContext(err, layer.Name, layer.Environment, "")
+			}
+			layer = resolved
+		}
+
+		rules := injectRulesFromFlags(cmd, appConfig.ProjectConfig(layer.Name, layer.Environment).Inject)
+		layers[i], err = service.ApplyInjectRules(layer, rules)
+		if err != nil {
+			return nil, &CommandError{Code: ExitUsage, Err: err, Project: layer.Name, Environment: layer.Environment}
+		}
 	}
 
-	if firstRun {
-		return fmt.Errorf("no vault found. Please run 'envy' to create a vault first")
+	setup := &runSetup{layers: layers, injected: service.MergeLayers(layers), unresolved: resolver.Warnings()}
+	setup.secrets = make(map[string]string, len(setup.injected))
+	for _, v := range setup.injected {
+		setup.secrets[v.Key] = v.Value
 	}
 
-	password, err := auth.PromptPassword("Enter master password: ")
+	if len(opts.asFiles) > 0 {
+		setup.secretDir, err = injectAsFiles(setup.injected, layers, opts.asFiles, dryRun)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	setup.env, setup.collisions = service.BuildEnvironment(opts.inherited, setup.injected)
+	return setup, nil
+}
+
+// report prints what was loaded and any overrides. With --no-override a
+// collision with an inherited variable is an error.
+func (s *runSetup) report(opts runOptions) error {
+	infof("Loaded %d secrets from %s\n", len(s.injected), describeLayers(s.layers))
+	for _, warning := range s.unresolved {
+		infof("Warning: %s\n", warning)
+	}
+	reportLayerConflicts(s.injected, s.layers)
+	if err := reportEnvCollisions(s.collisions, s.injected, s.layers, opts.noOverride); err != nil {
+		return err
+	}
+	if opts.explain {
+		explainLayers(s.injected, s.layers)
+	}
+	return nil
+}
+
+// writers returns where the command's output goes, filtered with --mask.
+// Call flush once the command has exited.
+func (s *runSetup) writers(mask bool) (stdout, stderr io.Writer, flush func()) {
+	if !mask {
+		return os.Stdout, os.Stderr, func() {}
+	}
+	outRedactor := service.NewRedactor(os.Stdout, s.secrets)
+	errRedactor := service.NewRedactor(os.Stderr, s.secrets)
+	return outRedactor, errRedactor, func() {
+		outRedactor.Flush()
+		errRedactor.Flush()
+	}
+}
+
+// cleanup removes the files written for --as-file
+func (s *runSetup) cleanup() {
+	if s.secretDir != nil {
+		s.secretDir.Remove()
+	}
+}
+
+// resolveLayerSpecs pins every project[:env] argument to the project it names
+func resolveLayerSpecs(projects []domain.Project, specs []string, defaultEnv string) ([]string, error) {
+	layers, err := resolveLayers(projects, specs, defaultEnv)
 	if err != nil {
-		return fmt.Errorf("failed to read password: %w", err)
+		return nil, err
 	}
+	pinned := make([]string, len(layers))
+	for i, layer := range layers {
+		pinned[i] = layer.Name + ":" + layer.Environment
+	}
+	return pinned, nil
+}
+
+// resolveLayers looks up every project[:env] argument given to 'envy run'
+func resolveLayers(projects []domain.Project, specs []string, defaultEnv string) ([]domain.Project, error) {
+	layers := make([]domain.Project, 0, len(specs))
+	for _, spec := range specs {
+		name, env := service.ParseProjectSpec(spec)
+		if env == "" {
+			env = defaultEnv
+		}
+
+		project, err := resolveProject(projects, name, env)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, *project)
+	}
+	return layers, nil
+}
+
+// injectRulesFromFlags overlays the --only, --exclude, --strip-prefix, --prefix
+// and --map flags on the rules configured for a project.
+func injectRulesFromFlags(cmd *cobra.Command, rules config.InjectRules) config.InjectRules {
+	flags := cmd.Flags()
+
+	if flags.Changed("only") {
+		rules.Only, _ = flags.GetStringSlice("only")
+	}
+	if flags.Changed("exclude") {
+		rules.Exclude, _ = flags.GetStringSlice("exclude")
+	}
+	if flags.Changed("strip-prefix") {
+		rules.StripPrefix, _ = flags.GetString("strip-prefix")
+	}
+	if flags.Changed("prefix") {
+		rules.Prefix, _ = flags.GetString("prefix")
+	}
+	if flags.Changed("map") {
+		cliMap, _ := flags.GetStringToString("map")
+		merged := make(map[string]string, len(rules.Map)+len(cliMap))
+		for stored, injected := range rules.Map {
+			merged[stored] = injected
+		}
+		for stored, injected := range cliMap {
+			merged[stored] = injected
+		}
+		rules.Map = merged
+	}
+
+	return rules
+}
 
-	projects, _, err := storage.Load(password)
+func layerLabel(project domain.Project) string {
+	return fmt.Sprintf("'%s' (%s)", project.Name, project.Environment)
+}
+
+func describeLayers(layers []domain.Project) string {
+	labels := make([]string, len(layers))
+	for i, layer := range layers {
+		labels[i] = layerLabel(layer)
+	}
+	return strings.Join(labels, ", ")
+}
+
+// reportLayerConflicts warns about keys whose value was replaced by a later layer
+func reportLayerConflicts(injected []service.LayeredValue, layers []domain.Project) {
+	for _, v := range injected {
+		for _, overridden := range v.Overridden {
+			infof("Warning: %s from %s overrides %s\n", v.Key, layerLabel(layers[v.Source]), layerLabel(layers[overridden]))
+		}
+	}
+}
+
+// injectAsFiles writes the named variables to files and replaces their values
+// with the paths. With dryRun only the names are checked.
+func injectAsFiles(injected []service.LayeredValue, layers []domain.Project, names []string, dryRun bool) (*storage.SecretDir, error) {
+	indexes := make([]int, len(names))
+	for i, name := range names {
+		indexes[i] = -1
+		for j, v := range injected {
+			if v.Key == name {
+				indexes[i] = j
+			}
+		}
+		if indexes[i] == -1 {
+			err := fmt.Errorf("--as-file: variable '%s' %w in %s", name, domain.ErrNotFound, describeLayers(layers))
+			return nil, withContext(err, "", "", name)
+		}
+	}
+	if dryRun {
+		return nil, nil
+	}
+
+	secretDir, err := storage.CreateSecretDir()
 	if err != nil {
-		return fmt.Errorf("failed to load vault: %w", err)
+		return nil, err
 	}
+	for _, i := range indexes {
+		path, err := secretDir.WriteFile(injected[i].Key, injected[i].Value)
+		if err != nil {
+			secretDir.Remove()
+			return nil, err
+		}
+		injected[i].Value = path
+	}
+	return secretDir, nil
+}
 
-	// Project search is case-insensitive and first first match wins
-	var project *domain.Project
-	for i := range projects {
-		if strings.EqualFold(projects[i].Name, projectName) {
-			project = &projects[i]
-			break
+// reportEnvCollisions warns about secrets replacing inherited variables, or
+// fails on the first one with --no-override
+func reportEnvCollisions(collisions []service.EnvCollision, injected []service.LayeredValue, layers []domain.Project, noOverride bool) error {
+	sources := make(map[string]domain.Project, len(injected))
+	for _, v := range injected {
+		sources[v.Key] = layers[v.Source]
+	}
+
+	for _, c := range collisions {
+		source := sources[c.Key]
+		if noOverride {
+			err := fmt.Errorf("%s from %s would replace the variable already set in the environment", c.Key, layerLabel(source))
+			return withContext(err, source.Name, source.Environment, c.Key)
 		}
+		infof("Warning: %s from %s replaces the variable already set in the environment\n", c.Key, layerLabel(source))
 	}
+	return nil
+}
 
-	if project == nil {
-		return fmt.Errorf("project '%s' not found", projectName)
+// printDryRun lists the environment the command would get, secrets masked
+func printDryRun(commandArgs, env []string, injected []service.LayeredValue, layers []domain.Project) error {
+	sources := make(map[string]domain.Project, len(injected))
+	for _, v := range injected {
+		sources[v.Key] = layers[v.Source]
 	}
 
-	// Build environment map
-	env := os.Environ()
+	result := runDryRunResult{Command: commandArgs, Environment: make([]runEnvVar, 0, len(env))}
+	for _, entry := range env {
+		name, value, _ := strings.Cut(entry, "=")
+		v := runEnvVar{Name: name, Value: value, Source: "inherited"}
+		if source, ok := sources[name]; ok {
+			v.Value = domain.MaskValue(value)
+			v.Source = fmt.Sprintf("%s:%s", source.Name, source.Environment)
+		}
+		result.Environment = append(result.Environment, v)
+	}
+
+	printResult(result, func() {
+		for _, v := range result.Environment {
+			if v.Source == "inherited" {
+				fmt.Printf("%s=%s\n", v.Name, v.Value)
+			} else {
+				fmt.Printf("%s=%s  # %s\n", v.Name, v.Value, v.Source)
+			}
+		}
+		if len(commandArgs) > 0 {
+			infof("\nWould run: %s\n", formatCommand(commandArgs))
+		}
+	})
+	return nil
+}
 
-	secretCount := 0
-	for _, key := range project.Keys {
-		envVar := fmt.Sprintf("%s=%s", key.Key, key.Current.Value)
-		env = append(env, envVar)
-		secretCount++
+func explainLayers(injected []service.LayeredValue, layers []domain.Project) {
+	infof("\n  %-30s %-30s %s\n", "VARIABLE", "LAYER", "VALUE")
+	for _, v := range injected {
+		infof("  %-30s %-30s %s\n", v.Key, layerLabel(layers[v.Source]), domain.MaskValue(v.Value))
 	}
+	infof("\n")
+}
+
+func executeCommand(args []string, env []string, stdout, stderr io.Writer) error {
+	// Catch signals before starting so none arrives while envy can't relay it
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
 
-	fmt.Fprintf(os.Stderr, "Loaded %d secrets from '%s' (%s)\n", secretCount, project.Name, project.Environment)
-	fmt.Fprintf(os.Stderr, "Running: %s\n\n", formatCommand(commandArgs))
+	command, done, err := startCommand(args, env, stdout, stderr)
+	if err != nil {
+		return err
+	}
 
-	return executeCommand(commandArgs, env)
+	for {
+		select {
+		case sig := <-signals:
+			forwardSignal(command, sig)
+		case err := <-done:
+			return commandResult(err)
+		}
+	}
 }
 
-func executeCommand(args []string, env []string) error {
+// startCommand starts the command and returns a channel receiving the
+// result of waiting for it
+func startCommand(args []string, env []string, stdout, stderr io.Writer) (*exec.Cmd, <-chan error, error) {
 	if len(args) == 0 {
-		return fmt.Errorf("no command specified")
+		return nil, nil, usageErrorf("no command specified")
 	}
 
-	cmdName := args[0]
-	cmdArgs := args[1:]
-
-	command := exec.Command(cmdName, cmdArgs...)
+	command := exec.Command(args[0], args[1:]...)
 	command.Env = env
 	command.Stdin = os.Stdin
-	command.Stdout = os.Stdout
-	command.Stderr = os.Stderr
+	command.Stdout = stdout
+	command.Stderr = stderr
 
-	if err := command.Run(); err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			os.Exit(exitErr.ExitCode())
-		}
-		return fmt.Errorf("command failed: %w", err)
+	if err := command.Start(); err != nil {
+		return nil, nil, startError(args[0], err)
 	}
 
+	done := make(chan error, 1)
+	go func() {
+		done <- command.Wait()
+	}()
+	return command, done, nil
+}
+
+// forwardSignal relays a signal envy received, unless the terminal already
+// delivered it to the command
+func forwardSignal(command *exec.Cmd, sig os.Signal) {
+	if !sentByTerminal(sig, command.Process.Pid) {
+		command.Process.Signal(sig)
+	}
+}
+
+// commandResult turns the command's exit status into envy's
+func commandResult(err error) error {
+	if err == nil {
+		return nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// The child already reported its failure, only propagate the status
+		return &CommandError{Code: childExitCode(exitErr.ProcessState), Err: err, quiet: true}
+	}
+	return fmt.Errorf("command failed: %w", err)
+}
+
+// replaceWithCommand execs the command in place of envy. It only returns if
+// the command couldn't be started.
+func replaceWithCommand(args []string, env []string) error {
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return startError(args[0], err)
+	}
+	if err := execReplace(path, args, env); err != nil {
+		return startError(args[0], err)
+	}
 	return nil
 }
 
+// childExitCode follows the shell convention of 128+N for a command killed by signal N
+func childExitCode(state *os.ProcessState) int {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return state.ExitCode()
+}
+
+// startError reports a command that couldn't be started with the exit codes
+// shells use: 127 when it wasn't found, 126 when it couldn't be executed.
+func startError(name string, err error) error {
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+		return &CommandError{Code: ExitCommandNotFound, Err: fmt.Errorf("command not found: %s", name)}
+	}
+	return &CommandError{Code: ExitCommandNotRunnable, Err: fmt.Errorf("failed to start %s: %w", name, err)}
+}
+
 // Helper function to format the command
 func formatCommand(args []string) string {
 	quoted := make([]string, len(args))
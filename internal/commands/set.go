@@ -1,30 +1,44 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
-	"strings"
+	"io"
+	"os"
 	"time"
 
 	"envy/internal/auth"
-	"envy/internal/config"
 	"envy/internal/domain"
-	"envy/internal/storage"
+	"envy/internal/service"
 
 	"github.com/spf13/cobra"
 )
 
 var setCmd = &cobra.Command{
-	Use:   "set [project] [KEY=VALUE]",
-	Short: "Set or update a secret in a project",
-	Long: `Set or update a secret (environment variable) in a project.
+	Use:   "set [project] [KEY=VALUE | KEY | KEY -]...",
+	Short: "Set or update secrets in a project",
+	Long: `Set or update one or more secrets (environment variables) in a project.
 
 If the project doesn't exist, you'll be prompted to create it.
-If the key already exists, it will be updated (old value saved to history).
+If a key already exists, it will be updated (old value saved to history).
+All secrets given in one invocation are saved with a single password prompt.
+
+Values can be given in several ways:
+  KEY=VALUE              value on the command line (visible in shell history)
+  KEY                    prompt for the value with hidden input
+  KEY -                  read the value from stdin
+  KEY --stdin            read the value from stdin
+  --from-file KEY=path   read the value from a file
+
+A single trailing newline is removed from values read from stdin.
 
 Examples:
   envy set myproject API_KEY=sk-1234567890
-  envy set "My Project" DATABASE_URL=postgresql://localhost/db
-  envy set production SECRET_TOKEN=abc123 -e prod
+  envy set myproject API_KEY=sk-123 DB_HOST=localhost DB_PORT=5432
+  envy set myproject API_KEY
+  pbpaste | envy set myproject API_KEY --stdin
+  vault read -field=token secret/ci | envy set myproject TOKEN -
+  envy set myproject --from-file TLS_CERT=./cert.pem -e prod
 
 Shorthand syntax (using -s flag):
   envy -s myproject API_KEY=sk-1234567890
@@ -32,98 +46,91 @@ Shorthand syntax (using -s flag):
   envy -s production SECRET_TOKEN=abc123 -e prod
 
 The -e/--env flag specifies the environment (default: dev).`,
-	Args: cobra.ExactArgs(2),
-	RunE: runSetCommand,
+	Args:              usageArgs(cobra.MinimumNArgs(1)),
+	RunE:              runSetCommand,
+	ValidArgsFunction: completeSetArgs,
 }
 
 func init() {
 	RootCmd.AddCommand(setCmd)
 	setCmd.Flags().StringP("env", "e", "dev", "Environment (dev, staging, prod)")
+	setCmd.Flags().Bool("stdin", false, "Read the value of the bare KEY argument from stdin")
+	setCmd.Flags().StringArray("from-file", nil, "Read a value from a file (KEY=path, repeatable)")
+	setCmd.RegisterFlagCompletionFunc("env", completeEnvironments)
 }
 
 func runSetCommand(cmd *cobra.Command, args []string) error {
 	projectName := args[0]
-	keyValuePair := args[1]
 	environment, _ := cmd.Flags().GetString("env")
+	useStdin, _ := cmd.Flags().GetBool("stdin")
+	fromFiles, _ := cmd.Flags().GetStringArray("from-file")
 
-	return performSet(projectName, keyValuePair, environment)
-}
-
-// performSet contains the core logic for setting a secret.
-func performSet(projectName, keyValuePair, environment string) error {
-	// Parse KEY=VALUE
-	parts := strings.SplitN(keyValuePair, "=", 2)
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid format. Expected: KEY=VALUE, got: %s", keyValuePair)
+	entries, err := collectSetEntries(args[1:], useStdin, fromFiles)
+	if err != nil {
+		return err
 	}
 
-	keyName := strings.TrimSpace(parts[0])
-	keyValue := parts[1] // Don't trim value, user might want leading/trailing spaces
-
-	if keyName == "" {
-		return fmt.Errorf("key name cannot be empty")
-	}
+	return performSet(projectName, entries, environment)
+}
 
-	if keyValue == "" {
-		return fmt.Errorf("key value cannot be empty")
+// collectSetEntries resolves every value source into key/value pairs before
+// the vault is unlocked, so that all keys are written in one save.
+func collectSetEntries(args []string, useStdin bool, fromFiles []string) ([]service.SetEntry, error) {
+	entries, err := service.CollectSetEntries(args, useStdin, fromFiles, service.SetSources{
+		Stdin: func() ([]byte, error) { return io.ReadAll(os.Stdin) },
+		Prompt: func(key string) (string, error) {
+			return auth.PromptSecret(fmt.Sprintf("Enter value for %s: ", key))
+		},
+		ReadFile: os.ReadFile,
+	})
+
+	var inputErr *service.SetInputError
+	if errors.As(err, &inputErr) {
+		return nil, &CommandError{Code: ExitUsage, Err: inputErr, Key: inputErr.Key}
 	}
+	return entries, err
+}
 
-	if err := domain.ValidateKeyName(keyName); err != nil {
-		return fmt.Errorf("invalid key name: %w", err)
-	}
+type setResult struct {
+	Project     string   `json:"project"`
+	Environment string   `json:"environment"`
+	Created     bool     `json:"created"`
+	Added       []string `json:"added"`
+	Updated     []string `json:"updated"`
+}
 
+// performSet contains the core logic for setting secrets.
+func performSet(projectName string, entries []service.SetEntry, environment string) error {
 	if err := domain.ValidateEnvironment(environment); err != nil {
 		return fmt.Errorf("invalid environment: %w", err)
 	}
 
-	appConfig := config.LoadAppConfig()
-	storage.SetConfig(appConfig.Backend)
-
-	if err := config.EnsureDirectories(); err != nil {
-		return fmt.Errorf("failed to create directories: %w", err)
-	}
-
-	firstRun, err := storage.IsFirstRun()
-	if err != nil {
-		return fmt.Errorf("failed to check vault status: %w", err)
-	}
-
-	if firstRun {
-		return fmt.Errorf("no vault found. Please run 'envy' to create a vault first")
-	}
-
-	password, err := auth.PromptPassword("Enter master password: ")
-	if err != nil {
-		return fmt.Errorf("failed to read password: %w", err)
-	}
-
-	projects, key, err := storage.Load(password)
+	projects, key, err := unlockVault()
 	if err != nil {
-		return fmt.Errorf("failed to load vault: %w", err)
+		return err
 	}
 
 	var project *domain.Project
-	projectIndex := -1
 	for i := range projects {
 		if projects[i].Name == projectName && projects[i].Environment == environment {
 			project = &projects[i]
-			projectIndex = i
 			break
 		}
 	}
 
+	result := setResult{Project: projectName, Environment: environment, Added: []string{}, Updated: []string{}}
+
 	// If project doesn't exist, ask to create it
 	if project == nil {
-		fmt.Printf("Project '%s' (%s) not found.\n", projectName, environment)
-		fmt.Println("Tip: Check the environment and use -e {environment} to specify it. Default is 'dev'.")
-		confirm, err := auth.PromptText("Create new project? [y/N]: ")
+		infof("Project '%s' (%s) not found.\n", projectName, environment)
+		infof("Tip: Check the environment and use -e {environment} to specify it. Default is 'dev'.\n")
+		ok, err := confirm("Create new project? [y/N]: ")
 		if err != nil {
-			return fmt.Errorf("failed to read input: %w", err)
+			return err
 		}
 
-		if strings.ToLower(confirm) != "y" && strings.ToLower(confirm) != "yes" {
-			fmt.Println("Operation cancelled.")
-			return nil
+		if !ok {
+			return withContext(ErrCancelled, projectName, environment, "")
 		}
 
 		newProject := domain.Project{
@@ -132,16 +139,34 @@ func performSet(projectName, keyValuePair, environment string) error {
 			Keys:        []domain.APIKey{},
 		}
 		projects = append(projects, newProject)
-		projectIndex = len(projects) - 1
-		project = &projects[projectIndex]
-		fmt.Printf("Created project '%s' (%s)\n", projectName, environment)
+		project = &projects[len(projects)-1]
+		result.Created = true
+		infof("Created project '%s' (%s)\n", projectName, environment)
+	}
+
+	for _, entry := range entries {
+		if setProjectKey(project, entry.Key, entry.Value) {
+			result.Updated = append(result.Updated, entry.Key)
+		} else {
+			result.Added = append(result.Added, entry.Key)
+		}
+	}
+
+	if err := saveVault(projects, key); err != nil {
+		return withContext(err, projectName, environment, "")
 	}
 
-	// Check if key already exists
-	keyExists := false
+	printResult(result, func() {
+		infof("Vault saved successfully\n")
+	})
+	return nil
+}
+
+// setProjectKey adds keyName to the project or updates it, moving the old value
+// to history. It reports whether an existing key was updated.
+func setProjectKey(project *domain.Project, keyName, keyValue string) bool {
 	for i, k := range project.Keys {
 		if k.Key == keyName {
-			keyExists = true
 			// Update existing key - old one is moved to history
 			oldValue := project.Keys[i].Current
 			project.Keys[i].History = append(project.Keys[i].History, oldValue)
@@ -150,32 +175,23 @@ func performSet(projectName, keyValuePair, environment string) error {
 				CreatedAt: time.Now(),
 				CreatedBy: "cli-set",
 			}
-			fmt.Printf("Updated '%s' in project '%s' (%s)\n", keyName, projectName, environment)
-			fmt.Printf("  Old value saved to history\n")
-			break
-		}
-	}
-
-	// Add new key if it doesn't exist
-	if !keyExists {
-		newKey := domain.APIKey{
-			Title: keyName,
-			Key:   keyName,
-			Current: domain.SecretVersion{
-				Value:     keyValue,
-				CreatedAt: time.Now(),
-				CreatedBy: "cli-set",
-			},
-			History: []domain.SecretVersion{},
+			infof("Updated '%s' in project '%s' (%s)\n", keyName, project.Name, project.Environment)
+			infof("  Old value saved to history\n")
+			return true
 		}
-		project.Keys = append(project.Keys, newKey)
-		fmt.Printf("Added '%s' to project '%s' (%s)\n", keyName, projectName, environment)
 	}
 
-	if err := storage.Save(projects, key); err != nil {
-		return fmt.Errorf("failed to save vault: %w", err)
+	newKey := domain.APIKey{
+		Title: keyName,
+		Key:   keyName,
+		Current: domain.SecretVersion{
+			Value:     keyValue,
+			CreatedAt: time.Now(),
+			CreatedBy: "cli-set",
+		},
+		History: []domain.SecretVersion{},
 	}
-
-	fmt.Println("Vault saved successfully")
-	return nil
+	project.Keys = append(project.Keys, newKey)
+	infof("Added '%s' to project '%s' (%s)\n", keyName, project.Name, project.Environment)
+	return false
 }
@@ -0,0 +1,427 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"envy/internal/domain"
+	"envy/internal/service"
+	"envy/internal/storage"
+
+	"github.com/spf13/cobra"
+)
+
+type identityResult struct {
+	Name        string `json:"name"`
+	PublicKey   string `json:"public_key"`
+	Fingerprint string `json:"fingerprint"`
+	Path        string `json:"path"`
+}
+
+type shareResult struct {
+	Project     string    `json:"project"`
+	Environment string    `json:"environment"`
+	Keys        int       `json:"keys"`
+	Recipient   string    `json:"recipient"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Path        string    `json:"path"`
+}
+
+type receiveResult struct {
+	Sender      service.BundleSender `json:"sender"`
+	Fingerprint string               `json:"fingerprint"`
+	CreatedAt   time.Time            `json:"created_at"`
+	importResult
+}
+
+var identityCmd = &cobra.Command{
+	Use:   "identity",
+	Short: "Manage the identity used to share projects",
+	Long: `Manage your sharing identity, used by 'envy share' and 'envy receive'.
+
+An identity is a key pair: others encrypt bundles to its public key, and
+bundles you send are signed with it. The private part is stored next to the
+vault, encrypted with the master password.
+
+Examples:
+  envy identity create --name alice
+  envy identity show > alice.pub`,
+}
+
+var identityCreateCmd = &cobra.Command{
+	Use:   "create [--name name]",
+	Short: "Create your sharing identity",
+	Long: `Create a new identity and print its public key. Give the public key
+to the people who share projects with you.
+
+Creating a new identity replaces the old one, after asking, and bundles
+made for the old one can no longer be opened.
+
+Examples:
+  envy identity create
+  envy identity create --name alice@laptop`,
+	Args: usageArgs(cobra.NoArgs),
+	RunE: runIdentityCreateCommand,
+}
+
+var identityShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print your public key",
+	Long: `Print the public key of your identity to stdout, with its name and
+fingerprint on stderr. The master password isn't needed.
+
+Examples:
+  envy identity show
+  envy identity show > alice.pub`,
+	Args: usageArgs(cobra.NoArgs),
+	RunE: runIdentityShowCommand,
+}
+
+var shareCmd = &cobra.Command{
+	Use:   "share <project[:env]> --to <public key|file> [-e env] [-o file]",
+	Short: "Encrypt a project for another user",
+	Long: `Write the current values of a project to a bundle only the recipient
+can open. The bundle is signed with your identity, records you as the
+sender and expires after --expires.
+
+--to takes a public key printed by 'envy identity show', or a file holding
+one. References to other keys are expanded unless --raw is given.
+
+Examples:
+  envy share myapp -e prod --to envypub1...
+  envy share myapp:dev --to bob.pub -o myapp.envy --expires 24h`,
+	Args:              usageArgs(cobra.ExactArgs(1)),
+	RunE:              runShareCommand,
+	ValidArgsFunction: completeProjectArg,
+}
+
+var receiveCmd = &cobra.Command{
+	Use:   "receive <bundle|->",
+	Short: "Import a bundle shared with you",
+	Long: `Check and decrypt a bundle made with 'envy share' and merge its keys
+into your vault. Changed keys are updated with the old value kept in
+history; keys missing from the bundle are kept.
+
+The bundle must be made for your identity, carry a valid signature and not
+be expired. The sender's name and fingerprint are shown; compare the
+fingerprint with the sender, or pass their public key with --from to check
+it automatically.
+
+Examples:
+  envy receive myapp.envy
+  envy receive myapp.envy --from alice.pub
+  envy receive myapp.envy -p myapp-staging -e stage`,
+	Args: usageArgs(cobra.ExactArgs(1)),
+	RunE: runReceiveCommand,
+}
+
+func init() {
+	RootCmd.AddCommand(identityCmd)
+	identityCmd.AddCommand(identityCreateCmd)
+	identityCmd.AddCommand(identityShowCmd)
+	identityCreateCmd.Flags().String("name", "", "Name recorded as the sender of your bundles (default user@host)")
+	identityCreateCmd.Flags().Bool("force", false, "Replace an existing identity without asking")
+
+	RootCmd.AddCommand(shareCmd)
+	shareCmd.Flags().StringP("env", "e", "", "Environment of the project (dev, stage, prod)")
+	shareCmd.Flags().String("to", "", "Recipient's public key, or a file holding it")
+	shareCmd.Flags().StringP("out", "o", "", "Bundle file, - for stdout (default <project>-<env>.envy)")
+	shareCmd.Flags().Duration("expires", 7*24*time.Hour, "How long the bundle can be received")
+	shareCmd.Flags().Bool("raw", false, "Share stored values without expanding references")
+	shareCmd.MarkFlagRequired("to")
+	shareCmd.RegisterFlagCompletionFunc("env", completeEnvironments)
+
+	RootCmd.AddCommand(receiveCmd)
+	receiveCmd.Flags().StringP("project", "p", "", "Import into this project instead of the bundle's")
+	receiveCmd.Flags().StringP("env", "e", "", "Import into this environment instead of the bundle's")
+	receiveCmd.Flags().String("from", "", "Only accept bundles from this public key, or the key in this file")
+	receiveCmd.RegisterFlagCompletionFunc("project", completeProjectFlag)
+	receiveCmd.RegisterFlagCompletionFunc("env", completeEnvironments)
+}
+
+func runIdentityCreateCommand(cmd *cobra.Command, args []string) error {
+	name, _ := cmd.Flags().GetString("name")
+	force, _ := cmd.Flags().GetBool("force")
+	if name == "" {
+		name = defaultIdentityName()
+	}
+
+	if _, err := storage.LoadIdentity(); err == nil && !force {
+		ok, err := confirm("An identity already exists. Bundles made for it can't be opened after replacing it. Replace? [y/N]: ")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("identity creation %w", ErrCancelled)
+		}
+	}
+
+	_, key, err := unlockVault()
+	if err != nil {
+		return err
+	}
+
+	identity, seed, err := service.NewIdentity(name)
+	if err != nil {
+		return err
+	}
+	publicKey := identity.PublicKey()
+	if err := storage.SaveIdentity(name, publicKey.String(), seed, key); err != nil {
+		return vaultError("failed to save identity", err)
+	}
+
+	result := identityResult{Name: name, PublicKey: publicKey.String(), Fingerprint: publicKey.Fingerprint(), Path: storage.IdentityPath()}
+	printResult(result, func() {
+		infof("Created identity '%s' (fingerprint %s)\n", result.Name, result.Fingerprint)
+		infof("Share this public key with people who send you projects:\n")
+		fmt.Println(result.PublicKey)
+	})
+	return nil
+}
+
+func runIdentityShowCommand(cmd *cobra.Command, args []string) error {
+	file, err := storage.LoadIdentity()
+	if err != nil {
+		return err
+	}
+	publicKey, err := service.ParsePublicKey(file.PublicKey)
+	if err != nil {
+		return vaultError("invalid identity file", err)
+	}
+
+	result := identityResult{Name: file.Name, PublicKey: file.PublicKey, Fingerprint: publicKey.Fingerprint(), Path: storage.IdentityPath()}
+	printResult(result, func() {
+		infof("Identity '%s' (fingerprint %s)\n", result.Name, result.Fingerprint)
+		fmt.Println(result.PublicKey)
+	})
+	return nil
+}
+
+func runShareCommand(cmd *cobra.Command, args []string) error {
+	projectName, environment := service.ParseProjectSpec(args[0])
+	if environment == "" {
+		environment, _ = cmd.Flags().GetString("env")
+	}
+	to, _ := cmd.Flags().GetString("to")
+	out, _ := cmd.Flags().GetString("out")
+	expires, _ := cmd.Flags().GetDuration("expires")
+	raw, _ := cmd.Flags().GetBool("raw")
+
+	if expires <= 0 {
+		return usageErrorf("--expires must be positive")
+	}
+	recipient, err := readPublicKey(to)
+	if err != nil {
+		return err
+	}
+	identityFile, err := storage.LoadIdentity()
+	if err != nil {
+		return err
+	}
+
+	projects, key, err := unlockVault()
+	if err != nil {
+		return err
+	}
+	identity, err := unlockIdentity(identityFile, key)
+	if err != nil {
+		return err
+	}
+
+	project, err := resolveProject(projects, projectName, environment)
+	if err != nil {
+		return err
+	}
+	if !raw {
+		resolver := service.NewResolver(projects)
+		resolved, err := resolver.Project(*project)
+		if err != nil {
+			return withContext(err, project.Name, project.Environment, "")
+		}
+		warnUnresolved(resolver)
+		project = &resolved
+	}
+
+	shared := service.SharedProject{Project: project.Name, Environment: project.Environment, Layout: project.Layout}
+	for _, k := range project.Keys {
+		shared.Keys = append(shared.Keys, service.ImportedKey{Key: k.Key, Value: k.Current.Value, Description: k.Description})
+	}
+
+	bundle, err := service.SealBundle(identity, recipient, shared, time.Now(), expires)
+	if err != nil {
+		return withContext(err, project.Name, project.Environment, "")
+	}
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if out == "" {
+		out = fmt.Sprintf("%s-%s.envy", strings.ReplaceAll(project.Name, "/", "-"), project.Environment)
+	}
+	if out == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := writePrivateFile(out, data); err != nil {
+		return withContext(err, project.Name, project.Environment, "")
+	}
+
+	result := shareResult{
+		Project:     project.Name,
+		Environment: project.Environment,
+		Keys:        len(shared.Keys),
+		Recipient:   recipient.Fingerprint(),
+		ExpiresAt:   bundle.ExpiresAt,
+		Path:        out,
+	}
+	printResult(result, func() {
+		infof("Shared %d keys of '%s' (%s) with %s in %s, valid until %s.\n",
+			result.Keys, result.Project, result.Environment, result.Recipient, result.Path, result.ExpiresAt.Local().Format(historyTimeFormat))
+	})
+	return nil
+}
+
+func runReceiveCommand(cmd *cobra.Command, args []string) error {
+	projectName, _ := cmd.Flags().GetString("project")
+	environment, _ := cmd.Flags().GetString("env")
+	from, _ := cmd.Flags().GetString("from")
+
+	if environment != "" {
+		if err := domain.ValidateEnvironment(environment); err != nil {
+			return err
+		}
+	}
+
+	data, err := readInput(args[0])
+	if err != nil {
+		return err
+	}
+	bundle, err := service.ParseBundle(data)
+	if err != nil {
+		return &CommandError{Code: ExitUsage, Err: err}
+	}
+	sender, err := service.ParsePublicKey(bundle.Sender.PublicKey)
+	if err != nil {
+		return &CommandError{Code: ExitUsage, Err: fmt.Errorf("invalid bundle sender: %w", err)}
+	}
+	if from != "" {
+		expected, err := readPublicKey(from)
+		if err != nil {
+			return err
+		}
+		if expected.String() != sender.String() {
+			return &CommandError{Code: ExitUsage, Err: fmt.Errorf("bundle was sent by '%s' (%s), not by the --from key (%s)",
+				bundle.Sender.Name, sender.Fingerprint(), expected.Fingerprint())}
+		}
+	}
+
+	identityFile, err := storage.LoadIdentity()
+	if err != nil {
+		return err
+	}
+	projects, key, err := unlockVault()
+	if err != nil {
+		return err
+	}
+	identity, err := unlockIdentity(identityFile, key)
+	if err != nil {
+		return err
+	}
+
+	shared, err := service.OpenBundle(bundle, identity, time.Now())
+	if err != nil {
+		return &CommandError{Code: ExitUsage, Err: err}
+	}
+	if projectName == "" {
+		projectName = shared.Project
+	}
+	if environment == "" {
+		environment = shared.Environment
+	}
+	if err := domain.ValidateProjectName(projectName); err != nil {
+		return err
+	}
+	if err := domain.ValidateEnvironment(environment); err != nil {
+		return err
+	}
+
+	infof("Bundle from '%s' (fingerprint %s), created %s\n", bundle.Sender.Name, sender.Fingerprint(), bundle.CreatedAt.Local().Format(historyTimeFormat))
+
+	batch := newImportBatch(args[0], projectName, environment, service.ImportDocument{Keys: shared.Keys, Layout: shared.Layout})
+	imported, err := applyImport(&projects, batch, service.ImportMerge)
+	if err != nil {
+		return err
+	}
+	if err := saveVault(projects, key); err != nil {
+		return err
+	}
+
+	result := receiveResult{Sender: bundle.Sender, Fingerprint: sender.Fingerprint(), CreatedAt: bundle.CreatedAt, importResult: imported}
+	printResult(result, func() {
+		infof("Received %s into project '%s' (%s):\n", args[0], imported.Project, imported.Environment)
+		printImportSummary(imported.ImportSummary)
+	})
+	return nil
+}
+
+// readPublicKey parses a public key given directly or as a file holding one
+func readPublicKey(arg string) (service.PublicKey, error) {
+	text := arg
+	if !strings.HasPrefix(arg, service.PublicKeyPrefix) {
+		data, err := os.ReadFile(arg)
+		if errors.Is(err, os.ErrNotExist) {
+			return service.PublicKey{}, usageErrorf("'%s' is neither a public key nor a file", arg)
+		}
+		if err != nil {
+			return service.PublicKey{}, &CommandError{Code: ExitUsage, Err: fmt.Errorf("failed to read %s: %w", arg, err)}
+		}
+
+		// Take the first key in the file, skipping comments
+		text = ""
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); strings.HasPrefix(line, service.PublicKeyPrefix) {
+				text = line
+				break
+			}
+		}
+		if text == "" {
+			return service.PublicKey{}, usageErrorf("%s holds no public key", arg)
+		}
+	}
+
+	key, err := service.ParsePublicKey(text)
+	if err != nil {
+		return service.PublicKey{}, usageErrorf("%v", err)
+	}
+	return key, nil
+}
+
+func unlockIdentity(file storage.IdentityFile, key []byte) (service.Identity, error) {
+	seed, err := file.DecryptSeed(key)
+	if err != nil {
+		return service.Identity{}, vaultError("failed to load identity", err)
+	}
+	identity, err := service.IdentityFromSeed(file.Name, seed)
+	if err != nil {
+		return service.Identity{}, vaultError("failed to load identity", err)
+	}
+	return identity, nil
+}
+
+func defaultIdentityName() string {
+	name := "envy"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+	return name
+}
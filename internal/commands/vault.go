@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"envy/internal/auth"
+	"envy/internal/domain"
+	"envy/internal/service"
+	"envy/internal/storage"
+)
+
+// errNoVault is returned by commands that need an existing vault
+var errNoVault = &CommandError{
+	Code: ExitVault,
+	Err:  fmt.Errorf("no vault found. Please run 'envy' to create a vault first"),
+}
+
+// promptUnlockPassword checks that a vault exists and asks for the master password
+func promptUnlockPassword() (string, error) {
+	firstRun, err := storage.IsFirstRun()
+	if err != nil {
+		return "", vaultError("failed to check vault status", err)
+	}
+
+	if firstRun {
+		return "", errNoVault
+	}
+
+	password, err := auth.PromptPassword("Enter master password: ")
+	if err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+// unlockVault prompts for the master password and returns the decrypted projects
+// together with the derived encryption key.
+func unlockVault() ([]domain.Project, []byte, error) {
+	password, err := promptUnlockPassword()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	projects, key, err := storage.Load(password)
+	if err != nil {
+		return nil, nil, vaultError("failed to load vault", err)
+	}
+	return projects, key, nil
+}
+
+// saveVault writes projects back to the vault
+func saveVault(projects []domain.Project, key []byte) error {
+	if err := storage.Save(projects, key); err != nil {
+		return vaultError("failed to save vault", err)
+	}
+	return nil
+}
+
+// findProject looks up a project by case-insensitive name and exact environment
+func findProject(projects []domain.Project, name, env string) (*domain.Project, error) {
+	for i := range projects {
+		if strings.EqualFold(projects[i].Name, name) && projects[i].Environment == env {
+			return &projects[i], nil
+		}
+	}
+	return nil, withContext(fmt.Errorf("project '%s' (%s) %w", name, env, domain.ErrNotFound), name, env, "")
+}
+
+// resolveProject finds the project named by the user for commands that read
+// secrets. Without env, a name present in several environments is resolved
+// through the configured default environment or reported as ambiguous.
+func resolveProject(projects []domain.Project, name, env string) (*domain.Project, error) {
+	if env != "" {
+		if err := domain.ValidateEnvironment(env); err != nil {
+			return nil, err
+		}
+	}
+
+	project, err := service.ResolveProject(projects, name, env, appConfig.Defaults.Environment)
+	if err != nil {
+		return nil, withContext(err, name, env, "")
+	}
+	return project, nil
+}
+
+// confirm asks a yes/no question and reports whether the user agreed
+func confirm(prompt string) (bool, error) {
+	answer, err := auth.PromptText(prompt)
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes", nil
+}
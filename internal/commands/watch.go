@@ -0,0 +1,139 @@
+package commands
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"time"
+
+	"envy/internal/service"
+	"envy/internal/storage"
+
+	"github.com/spf13/cobra"
+)
+
+// vaultPollInterval is how often 'envy run --watch' checks the vault file for saves
+const vaultPollInterval = time.Second
+
+// watchOptions control how 'envy run --watch' restarts the command
+type watchOptions struct {
+	signal os.Signal
+	grace  time.Duration
+}
+
+func watchOptionsFromFlags(cmd *cobra.Command) (watchOptions, error) {
+	name, _ := cmd.Flags().GetString("restart-signal")
+	grace, _ := cmd.Flags().GetDuration("grace")
+
+	sig, err := parseSignal(name)
+	if err != nil {
+		return watchOptions{}, &CommandError{Code: ExitUsage, Err: err}
+	}
+	if grace < 0 {
+		return watchOptions{}, usageErrorf("--grace can't be negative")
+	}
+	return watchOptions{signal: sig, grace: grace}, nil
+}
+
+// vaultStamp identifies one saved version of the vault file
+type vaultStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statVault() vaultStamp {
+	info, err := os.Stat(storage.VaultPath())
+	if err != nil {
+		return vaultStamp{}
+	}
+	return vaultStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+// watchCommand runs the command and restarts it whenever a save to the vault
+// changes the values it gets. It returns once the command exits by itself.
+func watchCommand(cmd *cobra.Command, opts runOptions, restart watchOptions, key []byte, setup *runSetup) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	ticker := time.NewTicker(vaultPollInterval)
+	defer ticker.Stop()
+	stamp := statVault()
+
+	for {
+		stdout, stderr, flush := setup.writers(opts.mask)
+		command, done, err := startCommand(opts.commandArgs, setup.env, stdout, stderr)
+		if err != nil {
+			return err
+		}
+
+		var next *runSetup
+		for next == nil {
+			select {
+			case sig := <-signals:
+				forwardSignal(command, sig)
+			case err := <-done:
+				flush()
+				return commandResult(err)
+			case <-ticker.C:
+				if current := statVault(); current != stamp {
+					stamp = current
+					next = reloadRun(cmd, opts, key, setup)
+				}
+			}
+		}
+
+		infof("Restarting: %s\n\n", formatCommand(opts.commandArgs))
+		stopCommand(command, done, restart)
+		flush()
+
+		// The deferred cleanup in runWithSecrets removes the latest files
+		setup.cleanup()
+		*setup = *next
+	}
+}
+
+// reloadRun prepares the command's environment from the saved vault. It
+// returns nil when the values are unchanged or the vault can't be used, in
+// which case the running command is kept.
+func reloadRun(cmd *cobra.Command, opts runOptions, key []byte, current *runSetup) *runSetup {
+	next, err := loadRun(cmd, opts, key)
+	var secrets map[string]string
+	if next != nil {
+		secrets = next.secrets
+	}
+
+	switch service.DecideReload(current.secrets, secrets, err) {
+	case service.KeepFailed:
+		infof("Warning: vault changed but secrets couldn't be loaded, keeping the running command: %v\n", err)
+		return nil
+	case service.KeepUnchanged:
+		next.cleanup()
+		return nil
+	}
+
+	infof("\nSecrets changed in the vault\n")
+	if err := next.report(opts); err != nil {
+		infof("Warning: keeping the running command: %v\n", err)
+		next.cleanup()
+		return nil
+	}
+	return next
+}
+
+// loadRun reads the vault with key and prepares the command's environment
+func loadRun(cmd *cobra.Command, opts runOptions, key []byte) (*runSetup, error) {
+	projects, err := storage.LoadWithKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return prepareRun(cmd, opts, projects, false)
+}
+
+// stopCommand sends the restart signal and waits for the command to exit,
+// killing it once the grace period is over
+func stopCommand(command *exec.Cmd, done <-chan error, restart watchOptions) {
+	if service.StopProcess(command.Process, done, restart.signal, restart.grace) {
+		infof("Command didn't exit within %s and was killed\n", restart.grace)
+	}
+}
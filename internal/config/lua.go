@@ -12,6 +12,13 @@ import (
 type AppConfig struct {
 	Backend BackendConfig
 
+	Defaults DefaultsConfig
+
+	// Projects holds per-project settings keyed by "name" or "name:env"
+	Projects map[string]ProjectConfig
+
+	Import ImportConfig
+
 	Keys KeyMap
 
 	Theme Theme
@@ -23,6 +30,45 @@ type BackendConfig struct {
 	LockPath string
 }
 
+// DefaultsConfig holds CLI defaults
+type DefaultsConfig struct {
+	// Environment is chosen by 'envy run' and '--export' when a project name
+	// exists in several environments and -e isn't given. Empty means ask.
+	Environment string
+}
+
+// ImportConfig holds settings for 'envy import'
+type ImportConfig struct {
+	// Environments maps .env file suffixes to environments for
+	// 'envy import --tree', on top of the built-in mapping. An empty
+	// environment ignores files with that suffix.
+	Environments map[string]string
+}
+
+// ProjectConfig holds settings for a single project
+type ProjectConfig struct {
+	Inject InjectRules
+}
+
+// InjectRules select and rename keys when a project is injected by 'envy run'.
+// Only and Exclude are glob patterns matched against stored key names.
+type InjectRules struct {
+	Only        []string
+	Exclude     []string
+	StripPrefix string
+	Prefix      string
+	Map         map[string]string
+}
+
+// ProjectConfig returns the settings for a project, preferring an entry for
+// "name:env" over one for "name".
+func (c AppConfig) ProjectConfig(name, env string) ProjectConfig {
+	if pc, ok := c.Projects[name+":"+env]; ok {
+		return pc
+	}
+	return c.Projects[name]
+}
+
 func DefaultBackendConfig() BackendConfig {
 	return BackendConfig{
 		KeysPath: GetDefaultKeysPath(),
@@ -64,6 +110,9 @@ func LoadAppConfig() AppConfig {
 	}
 
 	config.Backend = extractBackendConfig(L, config.Backend)
+	config.Defaults = extractDefaults(L, config.Defaults)
+	config.Projects = extractProjects(L)
+	config.Import = extractImport(L)
 	config.Keys = extractKeyMap(L, config.Keys)
 	config.Theme = extractTheme(L, config.Theme)
 
@@ -118,6 +167,109 @@ func extractBackendConfig(L *lua.LState, defaults BackendConfig) BackendConfig {
 	return config
 }
 
+func extractDefaults(L *lua.LState, defaults DefaultsConfig) DefaultsConfig {
+	config := defaults
+
+	defaultsTbl := L.GetGlobal("defaults")
+	if defaultsTbl.Type() != lua.LTTable {
+		return config
+	}
+
+	tbl := defaultsTbl.(*lua.LTable)
+
+	if val := tbl.RawGetString("environment"); val.Type() == lua.LTString {
+		config.Environment = string(val.(lua.LString))
+	}
+
+	return config
+}
+
+func extractImport(L *lua.LState) ImportConfig {
+	var config ImportConfig
+
+	importTbl := L.GetGlobal("import")
+	if importTbl.Type() != lua.LTTable {
+		return config
+	}
+
+	if val := importTbl.(*lua.LTable).RawGetString("environments"); val.Type() == lua.LTTable {
+		config.Environments = make(map[string]string)
+		val.(*lua.LTable).ForEach(func(suffix, env lua.LValue) {
+			if suffix.Type() == lua.LTString && env.Type() == lua.LTString {
+				config.Environments[string(suffix.(lua.LString))] = string(env.(lua.LString))
+			}
+		})
+	}
+
+	return config
+}
+
+func extractProjects(L *lua.LState) map[string]ProjectConfig {
+	projects := make(map[string]ProjectConfig)
+
+	projectsTbl := L.GetGlobal("projects")
+	if projectsTbl.Type() != lua.LTTable {
+		return projects
+	}
+
+	projectsTbl.(*lua.LTable).ForEach(func(name, value lua.LValue) {
+		if name.Type() != lua.LTString || value.Type() != lua.LTTable {
+			return
+		}
+
+		var pc ProjectConfig
+		if inject := value.(*lua.LTable).RawGetString("inject"); inject.Type() == lua.LTTable {
+			pc.Inject = extractInjectRules(inject.(*lua.LTable))
+		}
+		projects[string(name.(lua.LString))] = pc
+	})
+
+	return projects
+}
+
+func extractInjectRules(tbl *lua.LTable) InjectRules {
+	var rules InjectRules
+
+	rules.Only = luaStringList(tbl.RawGetString("only"))
+	rules.Exclude = luaStringList(tbl.RawGetString("exclude"))
+
+	if val := tbl.RawGetString("strip_prefix"); val.Type() == lua.LTString {
+		rules.StripPrefix = string(val.(lua.LString))
+	}
+	if val := tbl.RawGetString("prefix"); val.Type() == lua.LTString {
+		rules.Prefix = string(val.(lua.LString))
+	}
+
+	if val := tbl.RawGetString("map"); val.Type() == lua.LTTable {
+		rules.Map = make(map[string]string)
+		val.(*lua.LTable).ForEach(func(stored, injected lua.LValue) {
+			if stored.Type() == lua.LTString && injected.Type() == lua.LTString {
+				rules.Map[string(stored.(lua.LString))] = string(injected.(lua.LString))
+			}
+		})
+	}
+
+	return rules
+}
+
+// luaStringList reads a Lua array of strings, or a single string, into a slice
+func luaStringList(val lua.LValue) []string {
+	switch val.Type() {
+	case lua.LTString:
+		return []string{string(val.(lua.LString))}
+	case lua.LTTable:
+		var list []string
+		tbl := val.(*lua.LTable)
+		for i := 1; i <= tbl.Len(); i++ {
+			if item := tbl.RawGetInt(i); item.Type() == lua.LTString {
+				list = append(list, string(item.(lua.LString)))
+			}
+		}
+		return list
+	}
+	return nil
+}
+
 func extractKeyMap(L *lua.LState, defaults KeyMap) KeyMap {
 	config := defaults
 
@@ -2,6 +2,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -53,6 +54,29 @@ func GetDefaultConfigDir() string {
 	}
 }
 
+// GetRuntimeDir returns the directory for short-lived files such as secrets
+// written by 'envy run --as-file'. It prefers memory-backed locations:
+// - Linux: $XDG_RUNTIME_DIR/envy/, else /dev/shm/envy-<uid>/
+// - macOS: $TMPDIR/envy-<uid>/
+// - Windows: %TEMP%\envy\
+func GetRuntimeDir() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.TempDir(), "envy")
+	}
+
+	if xdgRuntime := os.Getenv("XDG_RUNTIME_DIR"); xdgRuntime != "" {
+		if info, err := os.Stat(xdgRuntime); err == nil && info.IsDir() {
+			return filepath.Join(xdgRuntime, "envy")
+		}
+	}
+
+	base := os.TempDir()
+	if info, err := os.Stat("/dev/shm"); err == nil && info.IsDir() {
+		base = "/dev/shm"
+	}
+	return filepath.Join(base, fmt.Sprintf("envy-%d", os.Getuid()))
+}
+
 func GetDefaultKeysPath() string {
 	return filepath.Join(GetDefaultDataDir(), "keys.json")
 }
@@ -14,6 +14,22 @@ const (
 	EnvDev   = "dev"
 )
 
+// ErrNotFound is wrapped by lookups of projects, keys and versions that don't exist
+var ErrNotFound = errors.New("not found")
+
+// ValidationError is returned by the Validate functions for malformed input
+type ValidationError struct {
+	msg string
+}
+
+func (e *ValidationError) Error() string {
+	return e.msg
+}
+
+func invalid(msg string) error {
+	return &ValidationError{msg: msg}
+}
+
 type SecretVersion struct {
 	Value     string    `json:"value"`
 	CreatedAt time.Time `json:"created_at"`
@@ -21,16 +37,28 @@ type SecretVersion struct {
 }
 
 type APIKey struct {
-	Title   string          `json:"title"`
-	Key     string          `json:"key"`
-	Current SecretVersion   `json:"current"`
-	History []SecretVersion `json:"history"`
+	Title string `json:"title"`
+	Key   string `json:"key"`
+	// Description is the comment block above the key in its dotenv file
+	Description string          `json:"description,omitempty"`
+	Current     SecretVersion   `json:"current"`
+	History     []SecretVersion `json:"history"`
+}
+
+// LayoutLine is a line of a project's dotenv file: the position of Key, or
+// when Key is empty a comment kept as Text, or a blank line if both are empty
+type LayoutLine struct {
+	Key  string `json:"key,omitempty"`
+	Text string `json:"text,omitempty"`
 }
 
 type Project struct {
 	Name        string   `json:"name"`
 	Environment string   `json:"environment"`
 	Keys        []APIKey `json:"keys"`
+	// Layout is the order, comments and blank lines of the dotenv file the
+	// project was imported from
+	Layout []LayoutLine `json:"layout,omitempty"`
 }
 
 type Store struct {
@@ -43,10 +71,10 @@ type Store struct {
 func ValidateProjectName(name string) error {
 	name = strings.TrimSpace(name)
 	if name == "" {
-		return errors.New("project name cannot be empty")
+		return invalid("project name cannot be empty")
 	}
 	if len(name) > 256 {
-		return errors.New("project name too long (max 256 characters)")
+		return invalid("project name too long (max 256 characters)")
 	}
 	return nil
 }
@@ -54,7 +82,7 @@ func ValidateProjectName(name string) error {
 func ValidateEnvironment(env string) error {
 	env = strings.TrimSpace(env)
 	if env != EnvProd && env != EnvDev && env != EnvStage {
-		return fmt.Errorf("invalid environment '%s' (must be prod, dev, or stage)", env)
+		return invalid(fmt.Sprintf("invalid environment '%s' (must be prod, dev, or stage)", env))
 	}
 	return nil
 }
@@ -62,13 +90,23 @@ func ValidateEnvironment(env string) error {
 func ValidateKeyName(name string) error {
 	name = strings.TrimSpace(name)
 	if name == "" {
-		return errors.New("key name cannot be empty")
+		return invalid("key name cannot be empty")
 	}
 	if strings.ContainsAny(name, "=\n\r") {
-		return errors.New("key name cannot contain =, newline, or carriage return")
+		return invalid("key name cannot contain =, newline, or carriage return")
 	}
 	if len(name) > 256 {
-		return errors.New("key name too long (max 256 characters)")
+		return invalid("key name too long (max 256 characters)")
 	}
 	return nil
 }
+
+// MaskValue hides a secret for display, keeping a short prefix and suffix of
+// long values so they can still be told apart.
+func MaskValue(value string) string {
+	runes := []rune(value)
+	if len(runes) < 12 {
+		return strings.Repeat("•", len(runes))
+	}
+	return string(runes[:2]) + strings.Repeat("•", 8) + string(runes[len(runes)-2:])
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+type bitwardenExport struct {
+	Encrypted bool `json:"encrypted"`
+	Folders   []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"folders"`
+	Collections []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"collections"`
+	Items []struct {
+		Name          string   `json:"name"`
+		FolderID      string   `json:"folderId"`
+		CollectionIDs []string `json:"collectionIds"`
+		Notes         string   `json:"notes"`
+		Login         *struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			TOTP     string `json:"totp"`
+			URIs     []struct {
+				URI string `json:"uri"`
+			} `json:"uris"`
+		} `json:"login"`
+		Fields []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"fields"`
+	} `json:"items"`
+}
+
+// ReadBitwarden reads an unencrypted Bitwarden JSON export. Items are placed
+// in their folder or, in organization exports, their first collection.
+func ReadBitwarden(data []byte) ([]ForeignItem, error) {
+	var export bitwardenExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("not a Bitwarden JSON export: %w", err)
+	}
+	if export.Encrypted {
+		return nil, errors.New("encrypted Bitwarden exports can't be read, export as unencrypted JSON instead")
+	}
+
+	folders := make(map[string]string)
+	for _, f := range export.Folders {
+		folders[f.ID] = f.Name
+	}
+	for _, c := range export.Collections {
+		folders[c.ID] = c.Name
+	}
+
+	items := make([]ForeignItem, 0, len(export.Items))
+	for _, entry := range export.Items {
+		item := ForeignItem{Name: entry.Name, Folder: folders[entry.FolderID]}
+		if item.Folder == "" && len(entry.CollectionIDs) > 0 {
+			item.Folder = folders[entry.CollectionIDs[0]]
+		}
+
+		if login := entry.Login; login != nil {
+			item.addField(FieldUsername, login.Username)
+			item.addField(FieldPassword, login.Password)
+			item.addField(FieldTOTP, login.TOTP)
+			if len(login.URIs) > 0 {
+				item.addField(FieldURL, login.URIs[0].URI)
+			}
+		}
+		for _, f := range entry.Fields {
+			item.addField(f.Name, f.Value)
+		}
+		item.addField(FieldNotes, entry.Notes)
+
+		items = append(items, item)
+	}
+	return items, nil
+}
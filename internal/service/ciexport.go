@@ -0,0 +1,159 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"envy/internal/domain"
+)
+
+// CIProviders lists the providers FormatCIExport writes for
+var CIProviders = []string{"github", "gitlab", "generic"}
+
+// CIFormats lists the formats FormatCIExport writes for gitlab and generic:
+// shell exports, or KEY=VALUE lines for a GitLab artifacts:reports:dotenv file
+var CIFormats = []string{"shell", "dotenv"}
+
+// DetectCIProvider names the CI system the job runs in from the variables
+// it sets, or "generic" for any other system and outside CI
+func DetectCIProvider(getenv func(string) string) string {
+	switch {
+	case getenv("GITHUB_ACTIONS") == "true":
+		return "github"
+	case getenv("GITLAB_CI") == "true":
+		return "gitlab"
+	}
+	return "generic"
+}
+
+// InCI reports whether the variables CI systems set say the job runs in CI,
+// where anything printed can end up in the job log
+func InCI(getenv func(string) string) bool {
+	return getenv("CI") != "" || getenv("GITLAB_CI") != "" || getenv("GITHUB_ACTIONS") != ""
+}
+
+// CIExport is a project written for a CI provider. Log is printed to the job
+// log and never holds a value: for GitHub Actions it asks the runner to mask
+// every value. Env holds the variables, for $GITHUB_ENV or, for other
+// providers, as shell exports to be evaluated by the job or as a dotenv
+// report. Unmasked names the keys whose values the job log shows as they are
+// if a step prints them: every key for providers that can't mask at run
+// time.
+type CIExport struct {
+	Log      []byte
+	Env      []byte
+	Masked   int
+	Unmasked []string
+}
+
+// FormatCIExport writes the current values of project for provider. format
+// is one of CIFormats for gitlab and generic, "" meaning shell, and must be
+// "" for github.
+func FormatCIExport(provider, format string, project domain.Project) (CIExport, error) {
+	var export CIExport
+	for _, k := range project.Keys {
+		if !identifierName.MatchString(k.Key) {
+			return CIExport{}, fmt.Errorf("key '%s' isn't a valid environment variable name", k.Key)
+		}
+	}
+
+	switch provider {
+	case "github":
+		if format != "" {
+			return CIExport{}, fmt.Errorf("the github provider always writes $GITHUB_ENV, format '%s' can't be used", format)
+		}
+		var log, env bytes.Buffer
+		masked := make(map[string]bool)
+		for _, k := range project.Keys {
+			for _, line := range maskLines(k.Current.Value) {
+				if !masked[line] {
+					masked[line] = true
+					fmt.Fprintf(&log, "::add-mask::%s\n", escapeWorkflowData(line))
+				}
+			}
+			if err := writeGitHubEnv(&env, k.Key, k.Current.Value); err != nil {
+				return CIExport{}, err
+			}
+		}
+		export = CIExport{Log: log.Bytes(), Env: env.Bytes(), Masked: len(masked)}
+	case "gitlab", "generic":
+		// Neither can mask a value at run time: GitLab only masks variables
+		// defined in the project's settings. Values only go to the job's
+		// shell or a file, never to its log, but nothing hides them later.
+		var env []byte
+		var err error
+		switch format {
+		case "", "shell":
+			env, err = FormatProject("shell", project)
+		case "dotenv":
+			env, err = formatDotenvReport(project)
+		default:
+			err = fmt.Errorf("unknown format '%s' (use %s)", format, strings.Join(CIFormats, ", "))
+		}
+		if err != nil {
+			return CIExport{}, err
+		}
+		export.Env = env
+		for _, k := range project.Keys {
+			export.Unmasked = append(export.Unmasked, k.Key)
+		}
+	default:
+		return CIExport{}, fmt.Errorf("unknown provider '%s' (use %s)", provider, strings.Join(CIProviders, ", "))
+	}
+	return export, nil
+}
+
+// formatDotenvReport writes KEY=VALUE lines as GitLab reads a dotenv report.
+// GitLab doesn't unquote values or read multiline ones, so values are written
+// as they are and a multiline value is an error.
+func formatDotenvReport(project domain.Project) ([]byte, error) {
+	var b bytes.Buffer
+	for _, k := range project.Keys {
+		if strings.ContainsAny(k.Current.Value, "\r\n") {
+			return nil, fmt.Errorf("value of '%s' has several lines, which a dotenv report can't hold", k.Key)
+		}
+		fmt.Fprintf(&b, "%s=%s\n", k.Key, k.Current.Value)
+	}
+	return b.Bytes(), nil
+}
+
+// maskLines returns what to mask for value. The runner masks line by line,
+// so each line of a multiline value is masked on its own.
+func maskLines(value string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(value, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// escapeWorkflowData escapes the data of a workflow command
+func escapeWorkflowData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// writeGitHubEnv appends one variable in the $GITHUB_ENV format. A multiline
+// value is written as a heredoc with a random delimiter, so the value can't
+// end it early.
+func writeGitHubEnv(b *bytes.Buffer, key, value string) error {
+	if !strings.ContainsAny(value, "\r\n") {
+		fmt.Fprintf(b, "%s=%s\n", key, value)
+		return nil
+	}
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return err
+	}
+	delimiter := "ENVY_EOF_" + hex.EncodeToString(random)
+	if strings.Contains(value, delimiter) {
+		return fmt.Errorf("value of '%s' contains its heredoc delimiter", key)
+	}
+	fmt.Fprintf(b, "%s<<%s\n%s\n%s\n", key, delimiter, value, delimiter)
+	return nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"sort"
+	"strings"
+
+	"envy/internal/domain"
+)
+
+// ProjectNameCandidates lists unique project names starting with toComplete,
+// ignoring case, each with its environments as the description
+func ProjectNameCandidates(projects []domain.Project, toComplete string) []string {
+	envs := make(map[string][]string)
+	var names []string
+	for _, p := range projects {
+		if !strings.HasPrefix(strings.ToLower(p.Name), strings.ToLower(toComplete)) {
+			continue
+		}
+		if _, ok := envs[p.Name]; !ok {
+			names = append(names, p.Name)
+		}
+		envs[p.Name] = append(envs[p.Name], p.Environment)
+	}
+	sort.Strings(names)
+
+	candidates := make([]string, len(names))
+	for i, name := range names {
+		candidates[i] = name + "\t" + strings.Join(envs[name], ", ")
+	}
+	return candidates
+}
+
+// KeyNameCandidates lists the keys of project/env starting with toComplete.
+// Without env the keys of every environment of the project are listed, each
+// name once.
+func KeyNameCandidates(projects []domain.Project, projectName, env, toComplete string) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, p := range projects {
+		if !strings.EqualFold(p.Name, projectName) || (env != "" && p.Environment != env) {
+			continue
+		}
+		for _, k := range p.Keys {
+			if strings.HasPrefix(k.Key, toComplete) && !seen[k.Key] {
+				seen[k.Key] = true
+				keys = append(keys, k.Key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ProjectSpecCandidates lists "project:env" for every project starting with
+// toComplete, ignoring case
+func ProjectSpecCandidates(projects []domain.Project, toComplete string) []string {
+	var specs []string
+	for _, p := range projects {
+		spec := p.Name + ":" + p.Environment
+		if strings.HasPrefix(strings.ToLower(spec), strings.ToLower(toComplete)) {
+			specs = append(specs, spec)
+		}
+	}
+	sort.Strings(specs)
+	return specs
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	"envy/internal/domain"
+)
+
+// DiffKind describes how a key differs between two projects
+type DiffKind int
+
+const (
+	DiffSame DiffKind = iota
+	DiffOnlyLeft
+	DiffOnlyRight
+	DiffChanged
+)
+
+// KeyDiff is the comparison result for a single key name
+type KeyDiff struct {
+	Key   string
+	Kind  DiffKind
+	Left  string
+	Right string
+}
+
+// DiffProjects compares the current values of two projects key by key.
+// Results are sorted by key name.
+func DiffProjects(left, right domain.Project) []KeyDiff {
+	leftValues := make(map[string]string, len(left.Keys))
+	for _, k := range left.Keys {
+		leftValues[k.Key] = k.Current.Value
+	}
+	rightValues := make(map[string]string, len(right.Keys))
+	for _, k := range right.Keys {
+		rightValues[k.Key] = k.Current.Value
+	}
+
+	var diffs []KeyDiff
+	for name, lv := range leftValues {
+		rv, ok := rightValues[name]
+		switch {
+		case !ok:
+			diffs = append(diffs, KeyDiff{Key: name, Kind: DiffOnlyLeft, Left: lv})
+		case lv != rv:
+			diffs = append(diffs, KeyDiff{Key: name, Kind: DiffChanged, Left: lv, Right: rv})
+		default:
+			diffs = append(diffs, KeyDiff{Key: name, Kind: DiffSame, Left: lv, Right: rv})
+		}
+	}
+	for name, rv := range rightValues {
+		if _, ok := leftValues[name]; !ok {
+			diffs = append(diffs, KeyDiff{Key: name, Kind: DiffOnlyRight, Right: rv})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Key < diffs[j].Key
+	})
+	return diffs
+}
+
+// HasDrift reports whether any key is missing on either side or changed
+func HasDrift(diffs []KeyDiff) bool {
+	for _, d := range diffs {
+		if d.Kind != DiffSame {
+			return true
+		}
+	}
+	return false
+}
+
+// Fingerprint returns a short salted HMAC of value so two values can be
+// compared visually without printing the plaintext. The salt should be
+// random per invocation so fingerprints can't be matched across runs.
+func Fingerprint(value string, salt []byte) string {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))[:12]
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"envy/internal/domain"
+
+	"github.com/hashicorp/go-envparse"
+)
+
+// exportHeaderPrefix starts the first line FormatProject writes, which
+// ParseDotenv drops so an exported file reads back the same
+const exportHeaderPrefix = "# Exported from Envy - "
+
+// ParseDotenv reads a dotenv file, keeping its keys in file order. A comment
+// block directly above a key becomes the key's description; other comments
+// and blank lines are kept in the layout. A repeated key keeps its first
+// position and its last value, as dotenv loaders do.
+func ParseDotenv(data []byte) (ImportDocument, error) {
+	values, err := envparse.Parse(bytes.NewReader(data))
+	if err != nil {
+		return ImportDocument{}, err
+	}
+
+	doc := ImportDocument{Layout: []domain.LayoutLine{}}
+	seen := make(map[string]bool, len(values))
+	var comments []string
+
+	keepComments := func() {
+		for _, c := range comments {
+			doc.Layout = append(doc.Layout, domain.LayoutLine{Text: c})
+		}
+		comments = nil
+	}
+
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if text == "" {
+		lines = nil
+	}
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case i == 0 && strings.HasPrefix(line, exportHeaderPrefix):
+			continue
+		case trimmed == "":
+			keepComments()
+			doc.Layout = append(doc.Layout, domain.LayoutLine{})
+			continue
+		case strings.HasPrefix(trimmed, "#"):
+			comments = append(comments, line)
+			continue
+		}
+
+		m := dotenvAssignment.FindStringSubmatch(line)
+		if m == nil {
+			keepComments()
+			continue
+		}
+
+		key := m[2]
+		if seen[key] {
+			keepComments()
+			continue
+		}
+		seen[key] = true
+
+		var description []string
+		for _, c := range comments {
+			description = append(description, commentText(c))
+		}
+		comments = nil
+
+		doc.Keys = append(doc.Keys, ImportedKey{Key: key, Value: values[key], Description: strings.Join(description, "\n")})
+		doc.Layout = append(doc.Layout, domain.LayoutLine{Key: key})
+	}
+	keepComments()
+
+	// Keys the line matcher doesn't recognize still get imported, at the end
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		if !seen[key] {
+			doc.Keys = append(doc.Keys, ImportedKey{Key: key, Value: values[key]})
+		}
+	}
+
+	return doc, nil
+}
+
+// commentText strips the comment marker and the space after it
+func commentText(line string) string {
+	line = strings.TrimLeft(line, " \t")
+	return strings.TrimPrefix(strings.TrimPrefix(line, "#"), " ")
+}
+
+// ApplyDotenvLayout records the layout and descriptions of an imported
+// dotenv file on project, after ImportKeys, and orders its keys to match.
+// With skip-existing only added keys take their description, and a project
+// that already has a layout keeps it.
+func ApplyDotenvLayout(project *domain.Project, imported []ImportedKey, layout []domain.LayoutLine, strategy string, summary ImportSummary) {
+	describe := make(map[string]string, len(imported))
+	for _, k := range imported {
+		describe[k.Key] = k.Description
+	}
+	if strategy == ImportSkipExisting {
+		describe = make(map[string]string, len(summary.Added))
+		for _, k := range imported {
+			if slices.Contains(summary.Added, k.Key) {
+				describe[k.Key] = k.Description
+			}
+		}
+	}
+
+	for i := range project.Keys {
+		if description, ok := describe[project.Keys[i].Key]; ok {
+			project.Keys[i].Description = description
+		}
+	}
+
+	if strategy != ImportSkipExisting || project.Layout == nil {
+		project.Layout = layout
+	}
+
+	position := make(map[string]int, len(project.Layout))
+	for i, line := range project.Layout {
+		if line.Key != "" {
+			position[line.Key] = i
+		}
+	}
+	slices.SortStableFunc(project.Keys, func(a, b domain.APIKey) int {
+		pa, okA := position[a.Key]
+		pb, okB := position[b.Key]
+		switch {
+		case okA && okB:
+			return cmp.Compare(pa, pb)
+		case okA:
+			return -1
+		case okB:
+			return 1
+		}
+		return 0
+	})
+}
+
+// writeDotenvKeys writes the keys of project in the order of its layout,
+// with their descriptions and the layout's comments and blank lines. Keys
+// the layout doesn't place follow at the end.
+func writeDotenvKeys(b *bytes.Buffer, project domain.Project) {
+	keys := make(map[string]domain.APIKey, len(project.Keys))
+	for _, k := range project.Keys {
+		keys[k.Key] = k
+	}
+
+	written := make(map[string]bool, len(project.Keys))
+	for _, line := range project.Layout {
+		if line.Key == "" {
+			b.WriteString(line.Text + "\n")
+			continue
+		}
+		if k, ok := keys[line.Key]; ok && !written[k.Key] {
+			writeDotenvKey(b, k)
+			written[k.Key] = true
+		}
+	}
+	for _, k := range project.Keys {
+		if !written[k.Key] {
+			writeDotenvKey(b, k)
+		}
+	}
+}
+
+func writeDotenvKey(b *bytes.Buffer, k domain.APIKey) {
+	if k.Description != "" {
+		for _, line := range strings.Split(k.Description, "\n") {
+			if line == "" {
+				b.WriteString("#\n")
+			} else {
+				fmt.Fprintf(b, "# %s\n", line)
+			}
+		}
+	}
+	fmt.Fprintf(b, "%s=%s\n", k.Key, dotenvValue(k.Current.Value))
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"envy/internal/domain"
+)
+
+// DotenvMerge is the result of MergeDotenv
+type DotenvMerge struct {
+	Content []byte
+	Updated []string // keys whose line was rewritten
+	Added   []string // keys appended at the end of the file
+	Kept    []string // keys in the file that the vault doesn't manage
+}
+
+var dotenvAssignment = regexp.MustCompile(`^(\s*(?:export\s+)?)([A-Za-z_][A-Za-z0-9_.-]*)(\s*=)(.*)$`)
+
+// MergeDotenv updates the keys of project in an existing dotenv file.
+// Comments, blank lines, ordering and keys the vault doesn't manage are
+// left as they are; managed keys missing from the file are appended.
+func MergeDotenv(existing []byte, project domain.Project) DotenvMerge {
+	values := make(map[string]string, len(project.Keys))
+	for _, k := range project.Keys {
+		values[k.Key] = k.Current.Value
+	}
+
+	var result DotenvMerge
+	var b bytes.Buffer
+	seen := make(map[string]bool)
+	kept := make(map[string]bool)
+
+	lines := strings.SplitAfter(string(existing), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		m := dotenvAssignment.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+		if m == nil {
+			b.WriteString(line)
+			continue
+		}
+
+		// A quoted value may continue on the following lines
+		valueStart := len(m[1]) + len(m[2]) + len(m[3])
+		end := i
+		span := line
+		for !valueClosed(span[valueStart:]) && end+1 < len(lines) {
+			end++
+			span += lines[end]
+		}
+
+		key := m[2]
+		value, managed := values[key]
+		if !managed {
+			b.WriteString(span)
+			i = end
+			if !kept[key] {
+				kept[key] = true
+				result.Kept = append(result.Kept, key)
+			}
+			continue
+		}
+
+		newline := "\n"
+		if strings.HasSuffix(span, "\r\n") {
+			newline = "\r\n"
+		} else if !strings.HasSuffix(span, "\n") {
+			newline = ""
+		}
+		fmt.Fprintf(&b, "%s%s=%s%s", m[1], key, dotenvValue(value), newline)
+		i = end
+
+		if !seen[key] {
+			seen[key] = true
+			result.Updated = append(result.Updated, key)
+		}
+	}
+
+	for _, k := range project.Keys {
+		if seen[k.Key] {
+			continue
+		}
+		if b.Len() > 0 && !bytes.HasSuffix(b.Bytes(), []byte("\n")) {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s=%s\n", k.Key, dotenvValue(k.Current.Value))
+		result.Added = append(result.Added, k.Key)
+	}
+
+	result.Content = b.Bytes()
+	return result
+}
+
+// valueClosed reports whether the value part of an assignment ends its
+// quotes, if it has any
+func valueClosed(value string) bool {
+	value = strings.TrimLeft(value, " \t")
+	if value == "" {
+		return true
+	}
+	quote := value[0]
+	if quote != '"' && quote != '\'' && quote != '`' {
+		return true
+	}
+	for i := 1; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && quote == '"':
+			i++
+		case value[i] == quote:
+			return true
+		}
+	}
+	return false
+}
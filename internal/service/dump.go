@@ -0,0 +1,295 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"envy/internal/crypto"
+	"envy/internal/domain"
+)
+
+const (
+	// DumpFormat identifies dump files
+	DumpFormat = "envy-dump"
+	// DumpVersion is the dump format version NewDump writes
+	DumpVersion = 1
+)
+
+// Dump is a complete copy of projects in plain text: values, descriptions,
+// layouts and, with IncludesHistory, every earlier version with its
+// timestamp and author
+type Dump struct {
+	Format          string           `json:"format"`
+	Version         int              `json:"version"`
+	CreatedAt       time.Time        `json:"created_at"`
+	IncludesHistory bool             `json:"includes_history"`
+	Projects        []domain.Project `json:"projects"`
+}
+
+// encryptedDump is a Dump encrypted with a key derived from a passphrase the
+// way the vault's key is derived from the master password
+type encryptedDump struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    string `json:"salt"`
+	Data    string `json:"data"`
+}
+
+// NewDump copies projects into a dump, leaving history out unless
+// includeHistory is set
+func NewDump(projects []domain.Project, includeHistory bool, now time.Time) Dump {
+	dump := Dump{
+		Format:          DumpFormat,
+		Version:         DumpVersion,
+		CreatedAt:       now.UTC(),
+		IncludesHistory: includeHistory,
+		Projects:        make([]domain.Project, len(projects)),
+	}
+	for i, p := range projects {
+		p.Keys = append([]domain.APIKey{}, p.Keys...)
+		if !includeHistory {
+			for j := range p.Keys {
+				p.Keys[j].History = []domain.SecretVersion{}
+			}
+		}
+		dump.Projects[i] = p
+	}
+	return dump
+}
+
+// Count returns the number of projects, keys and versions in the dump
+func (d Dump) Count() (projects, keys, versions int) {
+	for _, p := range d.Projects {
+		keys += len(p.Keys)
+		for _, k := range p.Keys {
+			versions += 1 + len(k.History)
+		}
+	}
+	return len(d.Projects), keys, versions
+}
+
+// MarshalDump writes dump as indented JSON, encrypted to passphrase unless
+// it is empty
+func MarshalDump(dump Dump, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		data, err := json.MarshalIndent(dump, "", "  ")
+		return append(data, '\n'), err
+	}
+
+	plaintext, err := json.Marshal(dump)
+	if err != nil {
+		return nil, err
+	}
+	salt, err := crypto.GenerateSalt()
+	if err != nil {
+		return nil, err
+	}
+	data, err := crypto.Encrypt(plaintext, crypto.DeriveKey(passphrase, salt))
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := json.MarshalIndent(encryptedDump{
+		Format:  DumpFormat,
+		Version: DumpVersion,
+		KDF:     "argon2id",
+		Salt:    base64.StdEncoding.EncodeToString(salt),
+		Data:    data,
+	}, "", "  ")
+	return append(out, '\n'), err
+}
+
+// ParseDump reads a dump file. passphrase is only called for an encrypted dump.
+func ParseDump(data []byte, passphrase func() (string, error)) (Dump, error) {
+	var header encryptedDump
+	if err := json.Unmarshal(data, &header); err != nil || header.Format != DumpFormat {
+		return Dump{}, errors.New("not an Envy dump")
+	}
+	if header.Version > DumpVersion {
+		return Dump{}, fmt.Errorf("dump version %d was made by a newer Envy", header.Version)
+	}
+
+	if header.KDF != "" {
+		if header.KDF != "argon2id" {
+			return Dump{}, fmt.Errorf("unsupported key derivation '%s'", header.KDF)
+		}
+		salt, err := base64.StdEncoding.DecodeString(header.Salt)
+		if err != nil {
+			return Dump{}, errors.New("invalid dump salt")
+		}
+		secret, err := passphrase()
+		if err != nil {
+			return Dump{}, err
+		}
+		data, err = crypto.Decrypt(header.Data, crypto.DeriveKey(secret, salt))
+		if err != nil {
+			return Dump{}, errors.New("failed to decrypt dump: wrong passphrase or damaged file")
+		}
+	}
+
+	var dump Dump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		return Dump{}, fmt.Errorf("invalid dump: %w", err)
+	}
+	return dump, nil
+}
+
+// VaultComparison lists how a vault differs from a dump. Entries name a
+// project as "name (env)" and a key as "name (env) KEY".
+type VaultComparison struct {
+	Projects  int      `json:"projects"`
+	Keys      int      `json:"keys"`
+	Versions  int      `json:"versions"`
+	Missing   []string `json:"missing"`
+	Extra     []string `json:"extra"`
+	Different []string `json:"different"`
+}
+
+// ValidateDump checks the names in a dump, which may have been edited by hand.
+// A project appears once per environment and a key once per project.
+func ValidateDump(dump Dump) error {
+	seen := make(map[string]bool)
+	for _, p := range dump.Projects {
+		if err := domain.ValidateProjectName(p.Name); err != nil {
+			return err
+		}
+		if err := domain.ValidateEnvironment(p.Environment); err != nil {
+			return fmt.Errorf("project '%s': %w", p.Name, err)
+		}
+		id := strings.ToLower(p.Name) + ":" + p.Environment
+		if seen[id] {
+			return fmt.Errorf("project '%s' (%s) appears twice", p.Name, p.Environment)
+		}
+		seen[id] = true
+
+		keys := make(map[string]bool, len(p.Keys))
+		for _, k := range p.Keys {
+			if err := domain.ValidateKeyName(k.Key); err != nil {
+				return fmt.Errorf("project '%s' (%s): %w", p.Name, p.Environment, err)
+			}
+			if keys[k.Key] {
+				return fmt.Errorf("project '%s' (%s): key '%s' appears twice", p.Name, p.Environment, k.Key)
+			}
+			keys[k.Key] = true
+		}
+	}
+	return nil
+}
+
+// Equivalent reports whether the vault holds exactly what the dump does
+func (c VaultComparison) Equivalent() bool {
+	return len(c.Missing) == 0 && len(c.Extra) == 0 && len(c.Different) == 0
+}
+
+// CompareVaults checks that vault holds the projects of dump with the same
+// keys in the same order, values, authors, timestamps and descriptions, and
+// with the same history when the dump includes it
+func CompareVaults(dump Dump, vault []domain.Project) VaultComparison {
+	c := VaultComparison{Missing: []string{}, Extra: []string{}, Different: []string{}}
+	c.Projects, c.Keys, c.Versions = dump.Count()
+
+	index := make(map[string]*domain.Project, len(vault))
+	for i := range vault {
+		index[vault[i].Name+"\x00"+vault[i].Environment] = &vault[i]
+	}
+	inDump := make(map[string]bool, len(dump.Projects))
+
+	for _, want := range dump.Projects {
+		id := want.Name + "\x00" + want.Environment
+		label := fmt.Sprintf("%s (%s)", want.Name, want.Environment)
+		inDump[id] = true
+
+		got, ok := index[id]
+		if !ok {
+			c.Missing = append(c.Missing, label)
+			continue
+		}
+		compareKeys(&c, label, want, *got, dump.IncludesHistory)
+	}
+
+	for _, p := range vault {
+		if !inDump[p.Name+"\x00"+p.Environment] {
+			c.Extra = append(c.Extra, fmt.Sprintf("%s (%s)", p.Name, p.Environment))
+		}
+	}
+	return c
+}
+
+// compareKeys matches the keys of want and got by name. A name given more
+// than once is matched occurrence by occurrence, so a duplicate on either
+// side is reported rather than hidden behind the first one.
+func compareKeys(c *VaultComparison, label string, want, got domain.Project, includeHistory bool) {
+	gotKeys := make(map[string][]domain.APIKey, len(got.Keys))
+	for _, k := range got.Keys {
+		gotKeys[k.Key] = append(gotKeys[k.Key], k)
+	}
+	wantCount := make(map[string]int, len(want.Keys))
+
+	var wantOrder []string
+	for _, w := range want.Keys {
+		n := wantCount[w.Key]
+		wantCount[w.Key]++
+		if n >= len(gotKeys[w.Key]) {
+			c.Missing = append(c.Missing, label+" "+w.Key)
+			continue
+		}
+		g := gotKeys[w.Key][n]
+		wantOrder = append(wantOrder, w.Key)
+
+		var diffs []string
+		if g.Title != w.Title || g.Description != w.Description {
+			diffs = append(diffs, "title or description")
+		}
+		if !sameVersion(g.Current, w.Current) {
+			diffs = append(diffs, "current value")
+		}
+		if includeHistory && !sameHistory(g.History, w.History) {
+			diffs = append(diffs, "history")
+		}
+		for _, d := range diffs {
+			c.Different = append(c.Different, fmt.Sprintf("%s %s: %s", label, w.Key, d))
+		}
+	}
+
+	var order []string
+	gotCount := make(map[string]int, len(got.Keys))
+	for _, k := range got.Keys {
+		n := gotCount[k.Key]
+		gotCount[k.Key]++
+		if n >= wantCount[k.Key] {
+			c.Extra = append(c.Extra, label+" "+k.Key)
+			continue
+		}
+		order = append(order, k.Key)
+	}
+	if !reflect.DeepEqual(order, wantOrder) {
+		c.Different = append(c.Different, label+": key order")
+	}
+	if len(got.Layout) != 0 || len(want.Layout) != 0 {
+		if !reflect.DeepEqual(got.Layout, want.Layout) {
+			c.Different = append(c.Different, label+": layout")
+		}
+	}
+}
+
+func sameVersion(a, b domain.SecretVersion) bool {
+	return a.Value == b.Value && a.CreatedBy == b.CreatedBy && a.CreatedAt.Equal(b.CreatedAt)
+}
+
+func sameHistory(a, b []domain.SecretVersion) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameVersion(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"runtime"
+	"strings"
+)
+
+// EnvCollision is an inherited variable replaced by an injected secret
+type EnvCollision struct {
+	Key       string
+	Inherited string
+}
+
+// KeepEnvironment returns the entries of environ, in "NAME=value" form, whose
+// name matches one of the patterns.
+func KeepEnvironment(environ []string, patterns []string) ([]string, error) {
+	var kept []string
+	for _, entry := range environ {
+		name, _, _ := strings.Cut(entry, "=")
+		ok, err := matchesAny(patterns, name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			kept = append(kept, entry)
+		}
+	}
+	return kept, nil
+}
+
+// BuildEnvironment adds the injected values to environ. Inherited variables
+// with the same name are removed rather than shadowed, since which duplicate
+// wins depends on the C library. Those holding a different value are
+// returned as collisions.
+func BuildEnvironment(environ []string, injected []LayeredValue) ([]string, []EnvCollision) {
+	index := make(map[string]int, len(injected))
+	for i, v := range injected {
+		index[envName(v.Key)] = i
+	}
+
+	env := make([]string, 0, len(environ)+len(injected))
+	var collisions []EnvCollision
+	for _, entry := range environ {
+		name, value, _ := strings.Cut(entry, "=")
+		i, replaced := index[envName(name)]
+		if !replaced {
+			env = append(env, entry)
+			continue
+		}
+		if value != injected[i].Value {
+			collisions = append(collisions, EnvCollision{Key: injected[i].Key, Inherited: value})
+		}
+	}
+
+	for _, v := range injected {
+		env = append(env, v.Key+"="+v.Value)
+	}
+	return env, collisions
+}
+
+// envName normalizes a variable name for comparison. Windows treats
+// environment names case-insensitively.
+func envName(name string) string {
+	if runtime.GOOS == "windows" {
+		return strings.ToUpper(name)
+	}
+	return name
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"envy/internal/domain"
+)
+
+// DefaultEnvironmentSuffixes maps the suffix of .env.<suffix> files to the
+// environment they hold
+var DefaultEnvironmentSuffixes = map[string]string{
+	"development": domain.EnvDev,
+	"dev":         domain.EnvDev,
+	"staging":     domain.EnvStage,
+	"stage":       domain.EnvStage,
+	"production":  domain.EnvProd,
+	"prod":        domain.EnvProd,
+}
+
+// EnvFile is a .env file found by FindEnvFiles with the project and
+// environment it belongs to
+type EnvFile struct {
+	Path        string `json:"path"`
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+}
+
+// SkippedEnvFile is a .env file FindEnvFiles can't place
+type SkippedEnvFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// FindEnvFiles walks root for .env.<suffix> files. The environment comes
+// from the suffix through suffixes, the project from the directory: its
+// path below root, or root's own name for files directly in it. Hidden
+// directories and node_modules are skipped. Files are returned in walk
+// order, which is sorted by path.
+func FindEnvFiles(root string, suffixes map[string]string) ([]EnvFile, []SkippedEnvFile, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var files []EnvFile
+	var skipped []SkippedEnvFile
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		name := d.Name()
+		if name != ".env" && !strings.HasPrefix(name, ".env.") {
+			return nil
+		}
+
+		suffix := strings.TrimPrefix(strings.TrimPrefix(name, ".env"), ".")
+		env := suffixes[suffix]
+		switch {
+		case suffix == "":
+			skipped = append(skipped, SkippedEnvFile{Path: path, Reason: "no environment suffix"})
+			return nil
+		case env == "":
+			skipped = append(skipped, SkippedEnvFile{Path: path, Reason: fmt.Sprintf("suffix '%s' isn't mapped to an environment", suffix)})
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		project := filepath.ToSlash(rel)
+		if project == "." {
+			project = filepath.Base(absRoot)
+		}
+
+		files = append(files, EnvFile{Path: path, Project: project, Environment: env})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return files, skipped, nil
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+
+	"envy/internal/domain"
+)
+
+// ExportFormats lists the formats FormatProject can write
+var ExportFormats = []string{"dotenv", "json", "yaml", "shell", "fish", "docker", "systemd", "properties", "toml"}
+
+var (
+	// identifierName is what shells and systemd accept as a variable name
+	identifierName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// plainDotenvValue needs no quotes in any common dotenv parser
+	plainDotenvValue = regexp.MustCompile(`^[A-Za-z0-9_./:@+,%-]*$`)
+
+	bareTOMLKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+	// yamlReservedWords are read as booleans or null by YAML 1.1 loaders
+	// such as PyYAML and Ruby's, in any of their spellings
+	yamlReservedWords = regexp.MustCompile(`(?i)^(y|yes|n|no|true|false|on|off|null)$`)
+)
+
+// FormatProject writes the current values of project in format, keeping the
+// order of its keys. Dotenv files also keep the comments and blank lines of
+// the project's layout. Values are escaped so the target reads them back
+// unchanged; a value the format can't represent is an error.
+func FormatProject(format string, project domain.Project) ([]byte, error) {
+	header := strings.TrimPrefix(exportHeaderPrefix, "# ") + fmt.Sprintf("Project: %s (%s)", project.Name, project.Environment)
+
+	var b bytes.Buffer
+	switch format {
+	case "json":
+		return formatJSON(project)
+	case "yaml":
+		fmt.Fprintf(&b, "# %s\n", header)
+		for _, k := range project.Keys {
+			fmt.Fprintf(&b, "%s: %s\n", yamlKey(k.Key), jsonString(k.Current.Value))
+		}
+	case "dotenv":
+		fmt.Fprintf(&b, "# %s\n", header)
+		writeDotenvKeys(&b, project)
+	case "shell":
+		fmt.Fprintf(&b, "# %s\n", header)
+		for _, k := range project.Keys {
+			if !identifierName.MatchString(k.Key) {
+				return nil, unsupportedKey(format, k.Key)
+			}
+			fmt.Fprintf(&b, "export %s=%s\n", k.Key, shellQuote(k.Current.Value))
+		}
+	case "fish":
+		fmt.Fprintf(&b, "# %s\n", header)
+		for _, k := range project.Keys {
+			if !identifierName.MatchString(k.Key) {
+				return nil, unsupportedKey(format, k.Key)
+			}
+			fmt.Fprintf(&b, "set -gx %s %s\n", k.Key, fishQuote(k.Current.Value))
+		}
+	case "docker":
+		// docker --env-file takes everything after '=' literally, so values
+		// can't be quoted and can't span lines
+		fmt.Fprintf(&b, "# %s\n", header)
+		for _, k := range project.Keys {
+			if strings.ContainsAny(k.Current.Value, "\n\r") {
+				return nil, fmt.Errorf("value of '%s' spans several lines, which docker --env-file can't represent", k.Key)
+			}
+			fmt.Fprintf(&b, "%s=%s\n", k.Key, k.Current.Value)
+		}
+	case "systemd":
+		fmt.Fprintf(&b, "# %s\n", header)
+		for _, k := range project.Keys {
+			if !identifierName.MatchString(k.Key) {
+				return nil, unsupportedKey(format, k.Key)
+			}
+			fmt.Fprintf(&b, "%s=%s\n", k.Key, systemdQuote(k.Current.Value))
+		}
+	case "properties":
+		fmt.Fprintf(&b, "# %s\n", header)
+		for _, k := range project.Keys {
+			fmt.Fprintf(&b, "%s=%s\n", propertiesEscape(k.Key, true), propertiesEscape(k.Current.Value, false))
+		}
+	case "toml":
+		fmt.Fprintf(&b, "# %s\n", header)
+		for _, k := range project.Keys {
+			fmt.Fprintf(&b, "%s = %s\n", tomlKey(k.Key), tomlString(k.Current.Value))
+		}
+	default:
+		return nil, fmt.Errorf("unknown format '%s' (use %s)", format, strings.Join(ExportFormats, ", "))
+	}
+	return b.Bytes(), nil
+}
+
+func unsupportedKey(format, key string) error {
+	return fmt.Errorf("key '%s' isn't a valid variable name for the %s format", key, format)
+}
+
+// formatJSON writes an object with the keys in project order
+func formatJSON(project domain.Project) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("{")
+	for i, k := range project.Keys {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, "\n  %s: %s", jsonString(k.Key), jsonString(k.Current.Value))
+	}
+	if len(project.Keys) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n")
+	return b.Bytes(), nil
+}
+
+// jsonString quotes s as a JSON string, which is also a valid YAML and
+// almost a valid TOML string
+func jsonString(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// yamlKey leaves a key bare only when every YAML loader reads it as a string
+func yamlKey(key string) string {
+	if identifierName.MatchString(key) && !yamlReservedWords.MatchString(key) {
+		return key
+	}
+	return jsonString(key)
+}
+
+// dotenvValue leaves simple values bare, single-quotes values without quotes
+// or line breaks (taken literally by dotenv parsers) and double-quotes the rest
+func dotenvValue(value string) string {
+	if plainDotenvValue.MatchString(value) {
+		return value
+	}
+	if !strings.ContainsAny(value, "'\n\r") {
+		return "'" + value + "'"
+	}
+
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", `\$`, "`", "\\`")
+	return `"` + r.Replace(value) + `"`
+}
+
+// shellQuote single-quotes value for POSIX shells, where nothing inside
+// single quotes is special. A quote closes the string, is escaped and
+// reopens it.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// fishQuote single-quotes value for fish, where \\ and \' are the only escapes
+func fishQuote(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + r.Replace(value) + "'"
+}
+
+// systemdQuote double-quotes value for EnvironmentFile=. Line breaks may
+// appear inside quotes as they are.
+func systemdQuote(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+	return `"` + r.Replace(value) + `"`
+}
+
+// propertiesEscape escapes s for java.util.Properties, which reads files as
+// ISO-8859-1: everything outside printable ASCII becomes \uXXXX
+func propertiesEscape(s string, isKey bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == ' ' && (isKey || i == 0):
+			b.WriteString(`\ `)
+		case (r == '#' || r == '!') && i == 0:
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case (r == '=' || r == ':') && isKey:
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			for _, unit := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&b, `\u%04X`, unit)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func tomlKey(key string) string {
+	if bareTOMLKey.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+// tomlString writes a TOML basic string
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,352 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"envy/internal/domain"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ImportFormats lists the formats ParseImport reads
+var ImportFormats = []string{"dotenv", "json", "yaml", "compose", "k8s"}
+
+// ImportDocument is a set of keys read from an import file. Name is set when
+// the file names it, as a compose service or a Kubernetes Secret does, and
+// Layout for dotenv files.
+type ImportDocument struct {
+	Name   string
+	Keys   []ImportedKey
+	Layout []domain.LayoutLine
+}
+
+// DetectImportFormat guesses the format of an import file from its name
+// and, for YAML, its contents
+func DetectImportFormat(path string, data []byte) string {
+	base := strings.ToLower(filepath.Base(path))
+	switch filepath.Ext(base) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		if strings.Contains(base, "compose") {
+			return "compose"
+		}
+		return detectYAML(data)
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return "json"
+	}
+	return "dotenv"
+}
+
+func detectYAML(data []byte) string {
+	docs, err := yamlDocuments(data)
+	if err != nil {
+		return "yaml"
+	}
+	for _, doc := range docs {
+		if scalarValue(mappingValue(doc, "kind")) == "Secret" {
+			return "k8s"
+		}
+	}
+	for _, doc := range docs {
+		if services := mappingValue(doc, "services"); services != nil && services.Kind == yaml.MappingNode {
+			return "compose"
+		}
+	}
+	return "yaml"
+}
+
+// ParseImport reads the keys in data. Nested JSON and YAML objects are
+// flattened by joining keys with separator, so {"db": {"host": ...}} becomes
+// db_host with "_". Every compose service with an environment section and
+// every Kubernetes Secret becomes a document of its own.
+func ParseImport(format string, data []byte, separator string) ([]ImportDocument, error) {
+	switch format {
+	case "dotenv":
+		doc, err := ParseDotenv(data)
+		if err != nil {
+			return nil, err
+		}
+		return []ImportDocument{doc}, nil
+	case "json", "yaml":
+		return parseNested(data, separator)
+	case "compose":
+		return parseCompose(data)
+	case "k8s":
+		return parseSecrets(data)
+	}
+	return nil, fmt.Errorf("unknown format '%s' (use %s)", format, strings.Join(ImportFormats, ", "))
+}
+
+// parseNested flattens every document into a single set of keys, later
+// documents overriding earlier ones
+func parseNested(data []byte, separator string) ([]ImportDocument, error) {
+	docs, err := yamlDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ImportDocument
+	for i, doc := range docs {
+		if doc.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("document %d: expected an object of keys, found %s", i+1, nodeKind(doc))
+		}
+		flatten(doc, "", separator, &result.Keys)
+	}
+	return []ImportDocument{result}, nil
+}
+
+func flatten(node *yaml.Node, prefix, separator string, keys *[]ImportedKey) {
+	join := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + separator + name
+	}
+
+	switch node.Kind {
+	case yaml.AliasNode:
+		flatten(node.Alias, prefix, separator, keys)
+	case yaml.MappingNode:
+		for _, pair := range mappingPairs(node) {
+			flatten(pair.value, join(pair.key), separator, keys)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			flatten(item, join(strconv.Itoa(i)), separator, keys)
+		}
+	case yaml.ScalarNode:
+		setKey(keys, prefix, scalarValue(node))
+	}
+}
+
+// parseCompose reads the environment section of every service, given either
+// as a mapping or as a list of KEY=VALUE. Variables without a value are
+// passed through from the shell by compose and are skipped.
+func parseCompose(data []byte) ([]ImportDocument, error) {
+	docs, err := yamlDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []ImportDocument
+	for _, doc := range docs {
+		services := mappingValue(doc, "services")
+		if services == nil || services.Kind != yaml.MappingNode {
+			continue
+		}
+		for _, pair := range mappingPairs(services) {
+			service := ImportDocument{Name: pair.key}
+			env := mappingValue(pair.value, "environment")
+			if env == nil {
+				continue
+			}
+
+			switch env.Kind {
+			case yaml.MappingNode:
+				for _, variable := range mappingPairs(env) {
+					value := resolveAlias(variable.value)
+					if value.Kind != yaml.ScalarNode || value.Tag == "!!null" {
+						continue
+					}
+					setKey(&service.Keys, variable.key, value.Value)
+				}
+			case yaml.SequenceNode:
+				for _, item := range env.Content {
+					key, value, ok := strings.Cut(scalarValue(item), "=")
+					if ok {
+						setKey(&service.Keys, key, value)
+					}
+				}
+			}
+			if len(service.Keys) > 0 {
+				result = append(result, service)
+			}
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, errors.New("no service has an environment section")
+	}
+	return result, nil
+}
+
+// parseSecrets reads every Kubernetes Secret, decoding data and letting
+// stringData win as the API server does. Other kinds are ignored.
+func parseSecrets(data []byte) ([]ImportDocument, error) {
+	docs, err := yamlDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []ImportDocument
+	for _, doc := range docs {
+		if scalarValue(mappingValue(doc, "kind")) != "Secret" {
+			continue
+		}
+		secret := ImportDocument{Name: scalarValue(mappingValue(mappingValue(doc, "metadata"), "name"))}
+
+		if encoded := mappingValue(doc, "data"); encoded != nil && encoded.Kind == yaml.MappingNode {
+			for _, pair := range mappingPairs(encoded) {
+				key := pair.key
+				value, err := base64.StdEncoding.DecodeString(scalarValue(pair.value))
+				if err != nil {
+					return nil, fmt.Errorf("secret '%s': data.%s is not valid base64: %w", secret.Name, key, err)
+				}
+				setKey(&secret.Keys, key, string(value))
+			}
+		}
+		if plain := mappingValue(doc, "stringData"); plain != nil && plain.Kind == yaml.MappingNode {
+			for _, pair := range mappingPairs(plain) {
+				setKey(&secret.Keys, pair.key, scalarValue(pair.value))
+			}
+		}
+		result = append(result, secret)
+	}
+
+	if len(result) == 0 {
+		return nil, errors.New("no document of kind Secret found")
+	}
+	return result, nil
+}
+
+// yamlDocuments returns the root node of every non-empty document in data.
+// JSON is read as YAML, which keeps the order of object keys.
+func yamlDocuments(data []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(doc.Content) > 0 {
+			root := resolveAlias(doc.Content[0])
+			if root.Kind == yaml.ScalarNode && root.Tag == "!!null" {
+				continue
+			}
+			docs = append(docs, root)
+		}
+	}
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	node = resolveAlias(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for _, pair := range mappingPairs(node) {
+		if pair.key == key {
+			return resolveAlias(pair.value)
+		}
+	}
+	return nil
+}
+
+// yamlPair is one entry of a mapping
+type yamlPair struct {
+	key   string
+	value *yaml.Node
+}
+
+// mappingPairs returns the entries of a mapping node with merge keys (<<)
+// expanded. A merge key takes a mapping or a list of mappings; keys given in
+// the mapping itself win over merged ones, and an earlier mapping in the list
+// wins over a later one.
+func mappingPairs(node *yaml.Node) []yamlPair {
+	explicit := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if !isMergeKey(node.Content[i]) {
+			explicit[node.Content[i].Value] = true
+		}
+	}
+
+	var pairs []yamlPair
+	seen := make(map[string]bool)
+	add := func(pair yamlPair) {
+		if !seen[pair.key] {
+			seen[pair.key] = true
+			pairs = append(pairs, pair)
+		}
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if !isMergeKey(key) {
+			add(yamlPair{key: key.Value, value: value})
+			continue
+		}
+
+		sources := []*yaml.Node{resolveAlias(value)}
+		if sources[0].Kind == yaml.SequenceNode {
+			sources = sources[0].Content
+		}
+		for _, source := range sources {
+			source = resolveAlias(source)
+			if source.Kind != yaml.MappingNode {
+				continue
+			}
+			for _, merged := range mappingPairs(source) {
+				if !explicit[merged.key] {
+					add(merged)
+				}
+			}
+		}
+	}
+	return pairs
+}
+
+func isMergeKey(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!merge"
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// scalarValue returns the text of a scalar node, "" for null or anything else
+func scalarValue(node *yaml.Node) string {
+	node = resolveAlias(node)
+	if node == nil || node.Kind != yaml.ScalarNode || node.Tag == "!!null" {
+		return ""
+	}
+	return node.Value
+}
+
+func nodeKind(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		return "a list"
+	case yaml.ScalarNode:
+		return "a single value"
+	}
+	return "something else"
+}
+
+// setKey adds key to keys, or replaces its value if it's already there
+func setKey(keys *[]ImportedKey, key, value string) {
+	for i := range *keys {
+		if (*keys)[i].Key == key {
+			(*keys)[i].Value = value
+			return
+		}
+	}
+	*keys = append(*keys, ImportedKey{Key: key, Value: value})
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"envy/internal/domain"
+)
+
+// Import strategies decide what happens to keys that already exist in the
+// target project
+const (
+	// ImportMerge updates changed keys, moving the old value into History
+	ImportMerge = "merge"
+	// ImportReplace merges, then removes keys the import doesn't contain
+	ImportReplace = "replace"
+	// ImportSkipExisting only adds keys the project doesn't have yet
+	ImportSkipExisting = "skip-existing"
+	// ImportFailOnConflict changes nothing if an existing key has another value
+	ImportFailOnConflict = "fail-on-conflict"
+)
+
+// ImportStrategies lists the strategies ImportKeys accepts
+var ImportStrategies = []string{ImportMerge, ImportReplace, ImportSkipExisting, ImportFailOnConflict}
+
+// ImportedKey is a key read from an import source
+type ImportedKey struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Description string `json:"description,omitempty"`
+}
+
+// ImportSummary lists what ImportKeys did with each key
+type ImportSummary struct {
+	Added     []string `json:"added"`
+	Updated   []string `json:"updated"`
+	Unchanged []string `json:"unchanged"`
+	Skipped   []string `json:"skipped"`
+	Removed   []string `json:"removed,omitempty"`
+}
+
+// ImportConflictError is returned by ImportFailOnConflict when existing keys
+// would change
+type ImportConflictError struct {
+	Keys []string
+}
+
+func (e *ImportConflictError) Error() string {
+	return fmt.Sprintf("%d existing keys have different values: %s", len(e.Keys), strings.Join(e.Keys, ", "))
+}
+
+// ImportKeys applies imported to project using strategy. New values are
+// recorded as created by createdBy at now. On error project is unchanged.
+func ImportKeys(project *domain.Project, imported []ImportedKey, strategy, createdBy string, now time.Time) (ImportSummary, error) {
+	summary := ImportSummary{Added: []string{}, Updated: []string{}, Unchanged: []string{}, Skipped: []string{}}
+
+	index := make(map[string]int, len(project.Keys))
+	for i, k := range project.Keys {
+		index[k.Key] = i
+	}
+
+	switch strategy {
+	case ImportMerge, ImportReplace, ImportSkipExisting:
+	case ImportFailOnConflict:
+		var conflicts []string
+		for _, k := range imported {
+			if i, ok := index[k.Key]; ok && project.Keys[i].Current.Value != k.Value {
+				conflicts = append(conflicts, k.Key)
+			}
+		}
+		if len(conflicts) > 0 {
+			return ImportSummary{}, &ImportConflictError{Keys: conflicts}
+		}
+	default:
+		return ImportSummary{}, fmt.Errorf("unknown import strategy '%s' (use %s)", strategy, strings.Join(ImportStrategies, ", "))
+	}
+
+	version := domain.SecretVersion{CreatedAt: now, CreatedBy: createdBy}
+	seen := make(map[string]bool, len(imported))
+
+	for _, k := range imported {
+		seen[k.Key] = true
+		i, exists := index[k.Key]
+
+		switch {
+		case !exists:
+			version.Value = k.Value
+			project.Keys = append(project.Keys, domain.APIKey{
+				Title:   k.Key,
+				Key:     k.Key,
+				Current: version,
+				History: []domain.SecretVersion{},
+			})
+			index[k.Key] = len(project.Keys) - 1
+			summary.Added = append(summary.Added, k.Key)
+		case project.Keys[i].Current.Value == k.Value:
+			summary.Unchanged = append(summary.Unchanged, k.Key)
+		case strategy == ImportSkipExisting:
+			summary.Skipped = append(summary.Skipped, k.Key)
+		default:
+			version.Value = k.Value
+			project.Keys[i].History = append(project.Keys[i].History, project.Keys[i].Current)
+			project.Keys[i].Current = version
+			summary.Updated = append(summary.Updated, k.Key)
+		}
+	}
+
+	if strategy == ImportReplace {
+		kept := project.Keys[:0]
+		for _, k := range project.Keys {
+			if seen[k.Key] {
+				kept = append(kept, k)
+			} else {
+				summary.Removed = append(summary.Removed, k.Key)
+			}
+		}
+		project.Keys = kept
+	}
+
+	return summary, nil
+}
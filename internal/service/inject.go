@@ -0,0 +1,76 @@
+package service
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"envy/internal/config"
+	"envy/internal/domain"
+)
+
+// ApplyInjectRules returns a copy of project holding only the keys selected
+// by rules, each renamed to the variable name it is injected as.
+//
+// Only and Exclude match stored key names. A key listed in Map takes that
+// name as is; other keys have StripPrefix removed and Prefix added.
+func ApplyInjectRules(project domain.Project, rules config.InjectRules) (domain.Project, error) {
+	result := domain.Project{
+		Name:        project.Name,
+		Environment: project.Environment,
+		Keys:        make([]domain.APIKey, 0, len(project.Keys)),
+	}
+	renamedFrom := make(map[string]string)
+
+	for _, apiKey := range project.Keys {
+		if len(rules.Only) > 0 {
+			selected, err := matchesAny(rules.Only, apiKey.Key)
+			if err != nil {
+				return domain.Project{}, err
+			}
+			if !selected {
+				continue
+			}
+		}
+
+		excluded, err := matchesAny(rules.Exclude, apiKey.Key)
+		if err != nil {
+			return domain.Project{}, err
+		}
+		if excluded {
+			continue
+		}
+
+		name, mapped := rules.Map[apiKey.Key]
+		if !mapped {
+			name = rules.Prefix + strings.TrimPrefix(apiKey.Key, rules.StripPrefix)
+		}
+
+		if err := domain.ValidateKeyName(name); err != nil {
+			return domain.Project{}, fmt.Errorf("key '%s' can't be injected as '%s': %w", apiKey.Key, name, err)
+		}
+		if other, taken := renamedFrom[name]; taken {
+			return domain.Project{}, fmt.Errorf("keys '%s' and '%s' would both be injected as '%s'", other, apiKey.Key, name)
+		}
+		renamedFrom[name] = apiKey.Key
+
+		renamed := apiKey
+		renamed.Key = name
+		result.Keys = append(result.Keys, renamed)
+	}
+
+	return result, nil
+}
+
+func matchesAny(patterns []string, name string) (bool, error) {
+	for _, pattern := range patterns {
+		ok, err := path.Match(pattern, name)
+		if err != nil {
+			return false, fmt.Errorf("invalid key pattern '%s': %w", pattern, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
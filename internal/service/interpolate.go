@@ -0,0 +1,210 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"envy/internal/domain"
+)
+
+// Resolver expands references in secret values:
+//
+//	${KEY}                   another key of the same project
+//	${ref:project/env/KEY}   a key of another project
+//	$${                      a literal "${"
+//
+// Referenced values are expanded in turn, so a chain of references follows
+// the latest value of every key. A ${NAME} that isn't a key of the project
+// and a "${" without a closing brace are left as they are, since values
+// stored before references existed may contain them, and reported by
+// Warnings. Results are cached, a Resolver should not outlive the projects
+// it was created for.
+type Resolver struct {
+	projects []domain.Project
+	cache    map[string]string
+	stack    []string
+	warnings []string
+}
+
+// NewResolver returns a Resolver looking up references in projects
+func NewResolver(projects []domain.Project) *Resolver {
+	return &Resolver{projects: projects, cache: make(map[string]string)}
+}
+
+// Value returns the expanded current value of a key of project
+func (r *Resolver) Value(project domain.Project, key string) (string, error) {
+	return r.resolveKey(&project, key)
+}
+
+// Reference returns the expanded value of ref, either a key of project or
+// "project/env/KEY" for a key of another project
+func (r *Resolver) Reference(project domain.Project, ref string) (string, error) {
+	if hasKey(&project, ref) {
+		return r.resolveKey(&project, ref)
+	}
+
+	name, env, key, ok := parseReference(ref)
+	if !ok {
+		return r.resolveKey(&project, ref)
+	}
+	other, err := ResolveProject(r.projects, name, env, "")
+	if err != nil {
+		return "", err
+	}
+	return r.resolveKey(other, key)
+}
+
+// Warnings lists the ${NAME} and unterminated "${" texts left as they are so far
+func (r *Resolver) Warnings() []string {
+	return r.warnings
+}
+
+// Project returns a copy of project with the current value of every key expanded
+func (r *Resolver) Project(project domain.Project) (domain.Project, error) {
+	resolved := project
+	resolved.Keys = make([]domain.APIKey, len(project.Keys))
+	copy(resolved.Keys, project.Keys)
+
+	for i := range resolved.Keys {
+		value, err := r.resolveKey(&project, resolved.Keys[i].Key)
+		if err != nil {
+			return domain.Project{}, err
+		}
+		resolved.Keys[i].Current.Value = value
+	}
+	return resolved, nil
+}
+
+func (r *Resolver) resolveKey(project *domain.Project, key string) (string, error) {
+	id := referenceID(project.Name, project.Environment, key)
+	if value, ok := r.cache[id]; ok {
+		return value, nil
+	}
+
+	for i, seen := range r.stack {
+		if seen == id {
+			chain := append(append([]string{}, r.stack[i:]...), id)
+			return "", fmt.Errorf("reference cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+
+	var apiKey *domain.APIKey
+	for i := range project.Keys {
+		if project.Keys[i].Key == key {
+			apiKey = &project.Keys[i]
+			break
+		}
+	}
+	if apiKey == nil {
+		return "", fmt.Errorf("key '%s' %w in project '%s' (%s)", key, domain.ErrNotFound, project.Name, project.Environment)
+	}
+
+	r.stack = append(r.stack, id)
+	value, err := r.expand(project, apiKey.Current.Value)
+	r.stack = r.stack[:len(r.stack)-1]
+	if err != nil {
+		return "", err
+	}
+
+	r.cache[id] = value
+	return value, nil
+}
+
+// expand replaces the references in value, which belongs to project
+func (r *Resolver) expand(project *domain.Project, value string) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.Index(value, "${")
+		if i < 0 {
+			b.WriteString(value)
+			return b.String(), nil
+		}
+
+		if i > 0 && value[i-1] == '$' {
+			b.WriteString(value[:i-1])
+			b.WriteString("${")
+			value = value[i+2:]
+			continue
+		}
+
+		end := strings.IndexByte(value[i+2:], '}')
+		if end < 0 {
+			// Generated passwords often contain a lone "${"
+			r.warnings = append(r.warnings, fmt.Sprintf("unterminated ${ in %s was left as is; write $${ to keep it literal", r.current()))
+			b.WriteString(value)
+			return b.String(), nil
+		}
+		ref := value[i+2 : i+2+end]
+
+		resolved, err := r.resolveRef(project, ref)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(value[:i])
+		b.WriteString(resolved)
+		value = value[i+3+end:]
+	}
+}
+
+func (r *Resolver) resolveRef(project *domain.Project, ref string) (string, error) {
+	target, ok := strings.CutPrefix(ref, "ref:")
+	if !ok {
+		if ref == "" {
+			return "", fmt.Errorf("empty reference ${} in %s", r.current())
+		}
+		if !hasKey(project, ref) {
+			r.warnings = append(r.warnings, fmt.Sprintf("${%s} in %s isn't a key of '%s' (%s) and was left as is; write $${%s} to keep it literal",
+				ref, r.current(), project.Name, project.Environment, ref))
+			return "${" + ref + "}", nil
+		}
+		return r.resolveKey(project, ref)
+	}
+
+	name, env, key, ok := parseReference(target)
+	if !ok {
+		return "", fmt.Errorf("invalid reference ${%s} in %s, expected ${ref:project/env/KEY}", ref, r.current())
+	}
+
+	other, err := ResolveProject(r.projects, name, env, "")
+	if err != nil {
+		return "", fmt.Errorf("reference ${%s} in %s: %w", ref, r.current(), err)
+	}
+	return r.resolveKey(other, key)
+}
+
+func hasKey(project *domain.Project, key string) bool {
+	for _, apiKey := range project.Keys {
+		if apiKey.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// current names the key being expanded, for error messages
+func (r *Resolver) current() string {
+	if len(r.stack) == 0 {
+		return "value"
+	}
+	return r.stack[len(r.stack)-1]
+}
+
+// parseReference splits "project/env/KEY". Project names and keys may
+// contain '/', so the environment is the first segment naming one.
+func parseReference(target string) (name, env, key string, ok bool) {
+	parts := strings.Split(target, "/")
+	for i := 1; i < len(parts)-1; i++ {
+		if domain.ValidateEnvironment(parts[i]) == nil {
+			return strings.Join(parts[:i], "/"), parts[i], strings.Join(parts[i+1:], "/"), true
+		}
+	}
+	return "", "", "", false
+}
+
+func referenceID(project, env, key string) string {
+	return project + "/" + env + "/" + key
+}
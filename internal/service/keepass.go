@@ -0,0 +1,73 @@
+package service
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+type keepassGroup struct {
+	Name    string         `xml:"Name"`
+	Entries []keepassEntry `xml:"Entry"`
+	Groups  []keepassGroup `xml:"Group"`
+}
+
+type keepassEntry struct {
+	Strings []struct {
+		Key   string `xml:"Key"`
+		Value string `xml:"Value"`
+	} `xml:"String"`
+}
+
+// keepassFields maps KeePass' standard entry strings to field names
+var keepassFields = map[string]string{
+	"UserName": FieldUsername,
+	"Password": FieldPassword,
+	"URL":      FieldURL,
+	"Notes":    FieldNotes,
+	"otp":      FieldTOTP,
+}
+
+// ReadKeePass reads a KeePass 2 XML export. The folder of an entry is the
+// path of groups below the root group; the recycle bin is left out.
+func ReadKeePass(data []byte) ([]ForeignItem, error) {
+	var file struct {
+		XMLName xml.Name       `xml:"KeePassFile"`
+		Groups  []keepassGroup `xml:"Root>Group"`
+	}
+	if err := xml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("not a KeePass XML export: %w", err)
+	}
+
+	var items []ForeignItem
+	for _, root := range file.Groups {
+		items = appendKeePassGroup(items, root, "")
+	}
+	return items, nil
+}
+
+func appendKeePassGroup(items []ForeignItem, group keepassGroup, folder string) []ForeignItem {
+	for _, entry := range group.Entries {
+		item := ForeignItem{Folder: folder}
+		for _, s := range entry.Strings {
+			if s.Key == "Title" {
+				item.Name = s.Value
+				continue
+			}
+			name, ok := keepassFields[s.Key]
+			if !ok {
+				name = s.Key
+			}
+			item.addField(name, s.Value)
+		}
+		items = append(items, item)
+	}
+
+	for _, sub := range group.Groups {
+		if sub.Name == "Recycle Bin" {
+			continue
+		}
+		items = appendKeePassGroup(items, sub, strings.TrimPrefix(folder+"/"+sub.Name, "/"))
+	}
+	return items
+}
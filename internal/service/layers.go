@@ -0,0 +1,46 @@
+package service
+
+import "envy/internal/domain"
+
+// LayeredValue is a variable produced by MergeLayers
+type LayeredValue struct {
+	Key   string
+	Value string
+
+	// Source is the index of the layer the value came from
+	Source int
+
+	// Overridden lists earlier layers that defined the key with a different value
+	Overridden []int
+}
+
+// MergeLayers combines the current values of several projects. Later layers
+// win: a key defined in more than one layer takes its value from the last
+// one. Variables are returned in order of first appearance.
+func MergeLayers(layers []domain.Project) []LayeredValue {
+	var merged []LayeredValue
+	index := make(map[string]int)
+
+	for layer, project := range layers {
+		for _, apiKey := range project.Keys {
+			i, seen := index[apiKey.Key]
+			if !seen {
+				index[apiKey.Key] = len(merged)
+				merged = append(merged, LayeredValue{
+					Key:    apiKey.Key,
+					Value:  apiKey.Current.Value,
+					Source: layer,
+				})
+				continue
+			}
+
+			if merged[i].Value != apiKey.Current.Value {
+				merged[i].Overridden = append(merged[i].Overridden, merged[i].Source)
+			}
+			merged[i].Value = apiKey.Current.Value
+			merged[i].Source = layer
+		}
+	}
+
+	return merged
+}
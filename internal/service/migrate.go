@@ -0,0 +1,240 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MigrationSources lists the secret managers whose exports can be migrated
+var MigrationSources = []string{"bitwarden", "keepass", "1password", "pass"}
+
+// Standard field names shared by every source. Custom fields keep the name
+// they were given.
+const (
+	FieldUsername = "username"
+	FieldPassword = "password"
+	FieldURL      = "url"
+	FieldNotes    = "notes"
+	FieldTOTP     = "totp"
+)
+
+// ForeignField is a named value of an item in another secret manager
+type ForeignField struct {
+	Name  string
+	Value string
+}
+
+// ForeignItem is an entry read from another secret manager's export. Folder
+// is the folder, group or vault holding it, "/"-separated when nested.
+type ForeignItem struct {
+	Folder string
+	Name   string
+	Fields []ForeignField
+}
+
+// addField adds a field unless its value is empty
+func (item *ForeignItem) addField(name, value string) {
+	if value != "" {
+		item.Fields = append(item.Fields, ForeignField{Name: name, Value: value})
+	}
+}
+
+// MigrationMapping decides which project every folder goes to and which
+// item fields become which keys. Key templates may use {item} and {field},
+// the item and field names in upper case with other characters turned into
+// underscores. An empty project or template skips the folder or field.
+//
+//	projects:
+//	  Work/Backend: backend
+//	  Personal: ""
+//	default_project: misc
+//	keys:
+//	  username: "{item}_USER"
+//	items:
+//	  GitHub:
+//	    password: GITHUB_TOKEN
+//	    "*": ""
+type MigrationMapping struct {
+	// Projects maps a folder to a project; other folders keep their name
+	Projects map[string]string `yaml:"projects"`
+	// DefaultProject receives items outside any folder
+	DefaultProject string `yaml:"default_project"`
+	// Keys maps a field name, or "*" for any other field, to a key template
+	Keys map[string]string `yaml:"keys"`
+	// Items overrides Keys for single items, by item name
+	Items map[string]map[string]string `yaml:"items"`
+}
+
+// DefaultMigrationMapping turns passwords into {item}, usernames into
+// {item}_USERNAME and custom fields into {item}_{field}. URLs, notes and
+// one-time password seeds are left out.
+func DefaultMigrationMapping() MigrationMapping {
+	return MigrationMapping{
+		Keys: map[string]string{
+			FieldPassword: "{item}",
+			FieldUsername: "{item}_USERNAME",
+			FieldURL:      "",
+			FieldNotes:    "",
+			FieldTOTP:     "",
+			"*":           "{item}_{field}",
+		},
+	}
+}
+
+// ParseMigrationMapping reads a mapping file, in YAML or JSON. Key templates
+// it doesn't set keep their defaults.
+func ParseMigrationMapping(data []byte) (MigrationMapping, error) {
+	var file MigrationMapping
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return MigrationMapping{}, err
+	}
+
+	mapping := DefaultMigrationMapping()
+	for field, template := range file.Keys {
+		mapping.Keys[field] = template
+	}
+	mapping.Projects = file.Projects
+	mapping.DefaultProject = file.DefaultProject
+	mapping.Items = file.Items
+	return mapping, nil
+}
+
+// MigratedKey is a key planned by PlanMigration, with the item and field it
+// comes from
+type MigratedKey struct {
+	Key   string `json:"key"`
+	Value string `json:"-"`
+	Item  string `json:"item"`
+	Field string `json:"field"`
+}
+
+// MigrationProject holds the keys planned for one project
+type MigrationProject struct {
+	Name string        `json:"project"`
+	Keys []MigratedKey `json:"keys"`
+}
+
+// MigrationPlan is what a migration will import, before anything is written
+type MigrationPlan struct {
+	Projects []MigrationProject `json:"projects"`
+	// Skipped lists folders with no project and items outside any folder
+	// when there's no default project
+	Skipped []string `json:"skipped,omitempty"`
+	// Warnings reports keys produced by more than one field
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+var nonKeyChars = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// PlanMigration applies mapping to items. Projects and keys keep the order
+// in which they first appear; when two fields produce the same key, the
+// later one wins and a warning is added.
+func PlanMigration(items []ForeignItem, mapping MigrationMapping) MigrationPlan {
+	var plan MigrationPlan
+	projectIndex := make(map[string]int)
+	skipped := make(map[string]bool)
+	sources := make(map[string]string)
+
+	for _, item := range items {
+		project, ok := mapping.project(item.Folder)
+		if !ok {
+			label := item.Folder
+			if label == "" {
+				label = item.Name + " (no folder)"
+			}
+			if !skipped[label] {
+				skipped[label] = true
+				plan.Skipped = append(plan.Skipped, label)
+			}
+			continue
+		}
+
+		i, exists := projectIndex[project]
+		if !exists {
+			i = len(plan.Projects)
+			projectIndex[project] = i
+			plan.Projects = append(plan.Projects, MigrationProject{Name: project})
+		}
+
+		for _, field := range item.Fields {
+			key := mapping.keyName(item.Name, field.Name)
+			if key == "" {
+				continue
+			}
+
+			source := item.Name + " › " + field.Name
+			id := project + "\x00" + key
+			if previous, dup := sources[id]; dup {
+				plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s in '%s': %s replaces %s", key, project, source, previous))
+			}
+			sources[id] = source
+
+			keys := &plan.Projects[i].Keys
+			migrated := MigratedKey{Key: key, Value: field.Value, Item: item.Name, Field: field.Name}
+			replaced := false
+			for j := range *keys {
+				if (*keys)[j].Key == key {
+					(*keys)[j] = migrated
+					replaced = true
+				}
+			}
+			if !replaced {
+				*keys = append(*keys, migrated)
+			}
+		}
+	}
+
+	// Folders whose items had no mapped fields import nothing
+	kept := plan.Projects[:0]
+	for _, p := range plan.Projects {
+		if len(p.Keys) > 0 {
+			kept = append(kept, p)
+		}
+	}
+	plan.Projects = kept
+	return plan
+}
+
+func (m MigrationMapping) project(folder string) (string, bool) {
+	if folder == "" {
+		return m.DefaultProject, m.DefaultProject != ""
+	}
+	if project, ok := m.Projects[folder]; ok {
+		return project, project != ""
+	}
+	return folder, true
+}
+
+// keyName returns the key for a field, looking at the item's own mapping
+// before the general one and at the field before "*"
+func (m MigrationMapping) keyName(item, field string) string {
+	var template string
+	for _, templates := range []map[string]string{m.Items[item], m.Keys} {
+		var ok bool
+		if template, ok = templates[field]; ok {
+			break
+		}
+		if template, ok = templates["*"]; ok {
+			break
+		}
+	}
+	if template == "" {
+		return ""
+	}
+
+	r := strings.NewReplacer("{item}", keyPart(item), "{field}", keyPart(field))
+	return r.Replace(template)
+}
+
+// keyPart turns a name like "GitHub token" into GITHUB_TOKEN
+func keyPart(name string) string {
+	return strings.Trim(nonKeyChars.ReplaceAllString(strings.ToUpper(name), "_"), "_")
+}
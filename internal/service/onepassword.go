@@ -0,0 +1,198 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type onePasswordItem struct {
+	Overview struct {
+		Title string `json:"title"`
+		URL   string `json:"url"`
+	} `json:"overview"`
+	Details struct {
+		LoginFields []struct {
+			Value       string `json:"value"`
+			Name        string `json:"name"`
+			Designation string `json:"designation"`
+		} `json:"loginFields"`
+		NotesPlain string `json:"notesPlain"`
+		Password   string `json:"password"`
+		Sections   []struct {
+			Fields []struct {
+				Title string                     `json:"title"`
+				ID    string                     `json:"id"`
+				Value map[string]json.RawMessage `json:"value"`
+			} `json:"fields"`
+		} `json:"sections"`
+	} `json:"details"`
+	// Some versions wrap every item in an object of its own
+	Item *onePasswordItem `json:"item"`
+}
+
+// ReadOnePassword reads a 1Password export, either a .1pux archive or a CSV
+// file. Items in a .1pux archive are placed in their vault; CSV exports only
+// name a vault if they have a "vault" column.
+func ReadOnePassword(data []byte) ([]ForeignItem, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return readOnePUX(data)
+	}
+	return readOnePasswordCSV(data)
+}
+
+func readOnePUX(data []byte) ([]ForeignItem, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not a .1pux archive: %w", err)
+	}
+	file, err := archive.Open("export.data")
+	if err != nil {
+		return nil, fmt.Errorf("not a .1pux archive: %w", err)
+	}
+	defer file.Close()
+
+	var export struct {
+		Accounts []struct {
+			Vaults []struct {
+				Attrs struct {
+					Name string `json:"name"`
+				} `json:"attrs"`
+				Items []onePasswordItem `json:"items"`
+			} `json:"vaults"`
+		} `json:"accounts"`
+	}
+	if err := json.NewDecoder(file).Decode(&export); err != nil {
+		return nil, fmt.Errorf("invalid export.data in .1pux archive: %w", err)
+	}
+
+	var items []ForeignItem
+	for _, account := range export.Accounts {
+		for _, vault := range account.Vaults {
+			for _, entry := range vault.Items {
+				if entry.Item != nil {
+					entry = *entry.Item
+				}
+				items = append(items, onePasswordFields(entry, vault.Attrs.Name))
+			}
+		}
+	}
+	return items, nil
+}
+
+func onePasswordFields(entry onePasswordItem, vault string) ForeignItem {
+	item := ForeignItem{Folder: vault, Name: entry.Overview.Title}
+
+	for _, f := range entry.Details.LoginFields {
+		switch f.Designation {
+		case FieldUsername, FieldPassword:
+			item.addField(f.Designation, f.Value)
+		default:
+			item.addField(f.Name, f.Value)
+		}
+	}
+	item.addField(FieldPassword, entry.Details.Password)
+	item.addField(FieldURL, entry.Overview.URL)
+
+	for _, section := range entry.Details.Sections {
+		for _, f := range section.Fields {
+			name := f.Title
+			if name == "" {
+				name = f.ID
+			}
+			for kind, raw := range f.Value {
+				var value string
+				if json.Unmarshal(raw, &value) != nil {
+					continue
+				}
+				if kind == "totp" {
+					name = FieldTOTP
+				}
+				item.addField(name, value)
+			}
+		}
+	}
+	item.addField(FieldNotes, entry.Details.NotesPlain)
+	return item
+}
+
+// onePasswordColumns maps CSV headers, in lower case, to field names. Other
+// columns become custom fields; bookkeeping columns are dropped.
+var onePasswordColumns = map[string]string{
+	"username":   FieldUsername,
+	"login":      FieldUsername,
+	"password":   FieldPassword,
+	"url":        FieldURL,
+	"website":    FieldURL,
+	"notes":      FieldNotes,
+	"otpauth":    FieldTOTP,
+	"totp":       FieldTOTP,
+	"favorite":   "",
+	"archived":   "",
+	"tags":       "",
+	"type":       "",
+	"category":   "",
+	"autosubmit": "",
+}
+
+func readOnePasswordCSV(data []byte) ([]ForeignItem, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("not a 1Password CSV export: %w", err)
+	}
+	titleColumn, vaultColumn := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "title", "name":
+			titleColumn = i
+		case "vault":
+			vaultColumn = i
+		}
+	}
+	if titleColumn < 0 {
+		return nil, errors.New("not a 1Password CSV export: no Title column")
+	}
+
+	var items []ForeignItem
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var item ForeignItem
+		for i, value := range record {
+			if i >= len(header) {
+				break
+			}
+			switch i {
+			case titleColumn:
+				item.Name = value
+				continue
+			case vaultColumn:
+				item.Folder = value
+				continue
+			}
+
+			name := strings.TrimSpace(header[i])
+			if field, ok := onePasswordColumns[strings.ToLower(name)]; ok {
+				name = field
+			}
+			if name != "" {
+				item.addField(name, value)
+			}
+		}
+		items = append(items, item)
+	}
+}
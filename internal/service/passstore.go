@@ -0,0 +1,84 @@
+package service
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// passFields maps the usual "name: value" lines of pass entries to field names
+var passFields = map[string]string{
+	"user":     FieldUsername,
+	"username": FieldUsername,
+	"login":    FieldUsername,
+	"url":      FieldURL,
+}
+
+// ReadPassStore reads every entry of a password-store directory, with
+// decrypt turning a .gpg file into its plain text. Following the pass
+// convention the first line is the password, later "name: value" lines are
+// fields and anything else makes up the notes. Hidden directories such as
+// .git are skipped.
+func ReadPassStore(root string, decrypt func(path string) ([]byte, error)) ([]ForeignItem, error) {
+	var items []ForeignItem
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".gpg" {
+			return nil
+		}
+
+		plain, err := decrypt(path)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		folder := filepath.ToSlash(filepath.Dir(rel))
+		if folder == "." {
+			folder = ""
+		}
+		items = append(items, parsePassEntry(folder, strings.TrimSuffix(filepath.Base(rel), ".gpg"), string(plain)))
+		return nil
+	})
+	return items, err
+}
+
+func parsePassEntry(folder, name, text string) ForeignItem {
+	item := ForeignItem{Folder: folder, Name: name}
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	item.addField(FieldPassword, strings.TrimSuffix(lines[0], "\r"))
+
+	var notes []string
+	for _, line := range lines[1:] {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.HasPrefix(line, "otpauth://") {
+			item.addField(FieldTOTP, line)
+			continue
+		}
+
+		// A bare URL like https://example.com isn't a field
+		key, value, ok := strings.Cut(line, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.HasPrefix(value, "//") {
+			notes = append(notes, line)
+			continue
+		}
+		if field, ok := passFields[strings.ToLower(key)]; ok {
+			key = field
+		}
+		item.addField(key, strings.TrimSpace(value))
+	}
+	item.addField(FieldNotes, strings.TrimSpace(strings.Join(notes, "\n")))
+	return item
+}
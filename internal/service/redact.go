@@ -0,0 +1,155 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"sort"
+)
+
+// MinRedactLength is the shortest value a Redactor hides. Shorter values
+// such as "1" or "dev" would mangle unrelated output.
+const MinRedactLength = 4
+
+type redactPattern struct {
+	text        []byte
+	replacement []byte
+}
+
+// Redactor is an io.Writer that replaces secret values in the stream with
+// ***KEY***. Raw, base64 and URL-encoded forms are matched, also when a value
+// is split across several writes.
+//
+// Only a trailing partial match is held back, so memory stays bounded by
+// the longest pattern and output ending in a newline is passed on at once
+// (unless a secret itself starts with one). Call Flush when the stream ends.
+type Redactor struct {
+	w        io.Writer
+	patterns []redactPattern
+	byFirst  map[byte][]int
+	pending  []byte
+}
+
+// NewRedactor returns a Redactor writing to w that hides secrets, a map of
+// variable name to value. When several names share a value the first name
+// in sorted order is shown.
+func NewRedactor(w io.Writer, secrets map[string]string) *Redactor {
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	r := &Redactor{w: w, byFirst: make(map[byte][]int)}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		value := secrets[name]
+		if len(value) < MinRedactLength {
+			continue
+		}
+		replacement := []byte("***" + name + "***")
+		for _, form := range encodedForms(value) {
+			if seen[form] {
+				continue
+			}
+			seen[form] = true
+			r.patterns = append(r.patterns, redactPattern{text: []byte(form), replacement: replacement})
+		}
+	}
+
+	// Longest first so a value wins over any shorter value it contains
+	sort.SliceStable(r.patterns, func(i, j int) bool {
+		return len(r.patterns[i].text) > len(r.patterns[j].text)
+	})
+	for i, p := range r.patterns {
+		r.byFirst[p.text[0]] = append(r.byFirst[p.text[0]], i)
+	}
+
+	return r
+}
+
+// encodedForms lists the ways a value may show up in output. Unpadded
+// base64 covers values embedded where the padding was stripped.
+func encodedForms(value string) []string {
+	raw := []byte(value)
+	return []string{
+		value,
+		base64.StdEncoding.EncodeToString(raw),
+		base64.URLEncoding.EncodeToString(raw),
+		base64.RawStdEncoding.EncodeToString(raw),
+		base64.RawURLEncoding.EncodeToString(raw),
+		url.QueryEscape(value),
+		url.PathEscape(value),
+	}
+}
+
+// Write redacts p and passes it on, holding back a trailing partial match
+func (r *Redactor) Write(p []byte) (int, error) {
+	r.pending = append(r.pending, p...)
+	out, rest := r.redact(r.pending, false)
+	r.pending = append(r.pending[:0], rest...)
+
+	if len(out) > 0 {
+		if _, err := r.w.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes out any held back bytes
+func (r *Redactor) Flush() error {
+	if len(r.pending) == 0 {
+		return nil
+	}
+	out, _ := r.redact(r.pending, true)
+	r.pending = r.pending[:0]
+	_, err := r.w.Write(out)
+	return err
+}
+
+// Buffered returns the number of bytes held back waiting for more input
+func (r *Redactor) Buffered() int {
+	return len(r.pending)
+}
+
+// redact replaces every match in buf. Unless final, it stops at the first
+// position where buf ends with the beginning of a pattern and returns the
+// remainder, which may complete on the next write.
+func (r *Redactor) redact(buf []byte, final bool) (out, rest []byte) {
+	out = make([]byte, 0, len(buf))
+	start := 0
+
+	for i := 0; i < len(buf); {
+		matched, partial := r.matchAt(buf[i:], final)
+		if partial {
+			return append(out, buf[start:i]...), buf[i:]
+		}
+		if matched == nil {
+			i++
+			continue
+		}
+		out = append(out, buf[start:i]...)
+		out = append(out, matched.replacement...)
+		i += len(matched.text)
+		start = i
+	}
+
+	return append(out, buf[start:]...), nil
+}
+
+// matchAt returns the pattern buf starts with, or reports that buf is a
+// proper prefix of a pattern and more input is needed to decide.
+func (r *Redactor) matchAt(buf []byte, final bool) (*redactPattern, bool) {
+	for _, i := range r.byFirst[buf[0]] {
+		p := &r.patterns[i]
+		if bytes.HasPrefix(buf, p.text) {
+			return p, false
+		}
+		if !final && len(buf) < len(p.text) && bytes.HasPrefix(p.text, buf) {
+			return nil, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"text/template"
+	"text/template/parse"
+
+	"envy/internal/domain"
+)
+
+// ParseTemplate parses a config file template for text/template. Besides
+// the built-in functions it offers:
+//
+//	secret "KEY"            a key of project, references expanded
+//	secret "proj/env/KEY"   a key of another project
+//	b64enc                  base64-encodes a string
+//	json                    encodes a value as JSON, e.g. a quoted string
+//	required "message"      fails with message when the value is empty
+func ParseTemplate(name, text string, resolver *Resolver, project domain.Project) (*template.Template, error) {
+	funcs := template.FuncMap{
+		"secret": func(ref string) (string, error) {
+			return resolver.Reference(project, ref)
+		},
+		"b64enc": func(s string) string {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		},
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+		"required": func(message string, v any) (any, error) {
+			if v == nil || v == "" {
+				return nil, errors.New(message)
+			}
+			return v, nil
+		},
+	}
+	return template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+}
+
+// TemplateSecrets lists the secrets a template asks for with a constant
+// argument, like {{ secret "KEY" }}, in every branch. Each is listed once.
+func TemplateSecrets(tmpl *template.Template) []string {
+	var refs []string
+	seen := make(map[string]bool)
+	add := func(ref string) {
+		if !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			walkTemplateNode(t.Tree.Root, add)
+		}
+	}
+	return refs
+}
+
+func walkTemplateNode(node parse.Node, add func(string)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkTemplateNode(child, add)
+		}
+	case *parse.ActionNode:
+		walkTemplateNode(n.Pipe, add)
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, add)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, add)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, add)
+	case *parse.TemplateNode:
+		walkTemplateNode(n.Pipe, add)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for i, cmd := range n.Cmds {
+			if !isIdentifier(cmd.Args[0], "secret") {
+				walkTemplateNode(cmd, add)
+				continue
+			}
+			if len(cmd.Args) > 1 {
+				if ref, ok := cmd.Args[1].(*parse.StringNode); ok {
+					add(ref.Text)
+				}
+			} else if i > 0 && len(n.Cmds[i-1].Args) == 1 {
+				// "KEY" | secret
+				if ref, ok := n.Cmds[i-1].Args[0].(*parse.StringNode); ok {
+					add(ref.Text)
+				}
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkTemplateNode(arg, add)
+		}
+	}
+}
+
+func walkBranch(n *parse.BranchNode, add func(string)) {
+	walkTemplateNode(n.Pipe, add)
+	walkTemplateNode(n.List, add)
+	walkTemplateNode(n.ElseList, add)
+}
+
+func isIdentifier(node parse.Node, name string) bool {
+	ident, ok := node.(*parse.IdentifierNode)
+	return ok && ident.Ident == name
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"envy/internal/domain"
+)
+
+// AmbiguousProjectError is returned by ResolveProject when a name matches
+// projects in several environments and none was chosen.
+type AmbiguousProjectError struct {
+	Name       string
+	Candidates []domain.Project
+}
+
+func (e *AmbiguousProjectError) Error() string {
+	envs := make([]string, len(e.Candidates))
+	for i, p := range e.Candidates {
+		envs[i] = fmt.Sprintf("%s (%s)", p.Name, p.Environment)
+	}
+	sort.Strings(envs)
+	return fmt.Sprintf("project '%s' is ambiguous, it matches: %s. Use -e/--env to choose one",
+		e.Name, strings.Join(envs, ", "))
+}
+
+// ResolveProject finds the project a user means by name. Names match
+// case-insensitively, preferring an exact-case match. When env is empty and
+// the name exists in several environments, defaultEnv breaks the tie if it is
+// one of them; otherwise an *AmbiguousProjectError lists the candidates.
+// The result never depends on the order of projects.
+func ResolveProject(projects []domain.Project, name, env, defaultEnv string) (*domain.Project, error) {
+	var candidates []*domain.Project
+	for i := range projects {
+		if !strings.EqualFold(projects[i].Name, name) {
+			continue
+		}
+		if env != "" && projects[i].Environment != env {
+			continue
+		}
+		candidates = append(candidates, &projects[i])
+	}
+
+	if len(candidates) == 0 {
+		if env != "" {
+			return nil, fmt.Errorf("project '%s' (%s) %w", name, env, domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("project '%s' %w", name, domain.ErrNotFound)
+	}
+
+	// Prefer exact-case names when case-insensitive matching found several
+	if len(candidates) > 1 {
+		var exact []*domain.Project
+		for _, p := range candidates {
+			if p.Name == name {
+				exact = append(exact, p)
+			}
+		}
+		if len(exact) > 0 {
+			candidates = exact
+		}
+	}
+
+	if len(candidates) > 1 && env == "" && defaultEnv != "" {
+		for _, p := range candidates {
+			if p.Environment == defaultEnv {
+				return p, nil
+			}
+		}
+	}
+
+	if len(candidates) > 1 {
+		ambiguous := &AmbiguousProjectError{Name: name}
+		for _, p := range candidates {
+			ambiguous.Candidates = append(ambiguous.Candidates, *p)
+		}
+		return nil, ambiguous
+	}
+
+	return candidates[0], nil
+}
+
+// ParseProjectSpec splits a "project:env" argument. The environment is empty
+// when the argument has no ":env" suffix.
+func ParseProjectSpec(spec string) (name, env string) {
+	if i := strings.LastIndex(spec, ":"); i > 0 {
+		candidate := spec[i+1:]
+		if domain.ValidateEnvironment(candidate) == nil {
+			return spec[:i], candidate
+		}
+	}
+	return spec, ""
+}
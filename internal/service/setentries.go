@@ -0,0 +1,141 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"envy/internal/domain"
+)
+
+// SetEntry is a key and its new value given to 'envy set'
+type SetEntry struct {
+	Key   string
+	Value string
+}
+
+// SetSources reads the values that aren't given on the command line
+type SetSources struct {
+	// Stdin reads all of standard input
+	Stdin func() ([]byte, error)
+	// Prompt asks for the value of key without echoing it
+	Prompt func(key string) (string, error)
+	// ReadFile reads a --from-file path
+	ReadFile func(path string) ([]byte, error)
+}
+
+// SetInputError is an argument of 'envy set' that can't be used. Key names
+// the key it concerns, if any.
+type SetInputError struct {
+	Key string
+	Err error
+}
+
+func (e *SetInputError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SetInputError) Unwrap() error {
+	return e.Err
+}
+
+func setInputErrorf(key, format string, args ...any) error {
+	return &SetInputError{Key: key, Err: fmt.Errorf(format, args...)}
+}
+
+// CollectSetEntries turns the arguments of 'envy set' into entries:
+// KEY=VALUE, a bare KEY that is prompted for, KEY followed by '-' or a bare
+// KEY with useStdin read from stdin, and KEY=path specs in fromFiles. Only one
+// key can read stdin, and a single trailing newline is removed from it.
+// Errors from the sources are returned as they are; anything wrong with the
+// arguments themselves is a *SetInputError.
+func CollectSetEntries(args []string, useStdin bool, fromFiles []string, src SetSources) ([]SetEntry, error) {
+	var entries []SetEntry
+	stdinUsed := false
+
+	readStdin := func(keyName string) (string, error) {
+		if stdinUsed {
+			return "", setInputErrorf(keyName, "only one key can read its value from stdin (second was '%s')", keyName)
+		}
+		stdinUsed = true
+
+		data, err := src.Stdin()
+		if err != nil {
+			return "", fmt.Errorf("failed to read stdin: %w", err)
+		}
+		value := strings.TrimSuffix(string(data), "\n")
+		return strings.TrimSuffix(value, "\r"), nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "-" {
+			return nil, setInputErrorf("", "'-' must follow a key name, e.g. 'envy set myproject API_KEY -'")
+		}
+
+		if keyName, keyValue, found := strings.Cut(arg, "="); found {
+			entries = append(entries, SetEntry{Key: strings.TrimSpace(keyName), Value: keyValue})
+			continue
+		}
+
+		keyName := strings.TrimSpace(arg)
+		var keyValue string
+		var err error
+
+		switch {
+		case i+1 < len(args) && args[i+1] == "-":
+			i++
+			keyValue, err = readStdin(keyName)
+		case useStdin:
+			keyValue, err = readStdin(keyName)
+		default:
+			keyValue, err = src.Prompt(keyName)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, SetEntry{Key: keyName, Value: keyValue})
+	}
+
+	for _, spec := range fromFiles {
+		keyName, path, found := strings.Cut(spec, "=")
+		keyName = strings.TrimSpace(keyName)
+		if !found || path == "" {
+			return nil, setInputErrorf("", "invalid --from-file value. Expected: KEY=path, got: %s", spec)
+		}
+
+		data, err := src.ReadFile(path)
+		if err != nil {
+			return nil, setInputErrorf(keyName, "failed to read value for '%s': %w", keyName, err)
+		}
+		entries = append(entries, SetEntry{Key: keyName, Value: string(data)})
+	}
+
+	if len(entries) == 0 {
+		return nil, setInputErrorf("", "no secrets given. Expected: envy set [project] KEY=VALUE")
+	}
+
+	if useStdin && !stdinUsed {
+		return nil, setInputErrorf("", "--stdin requires a bare KEY argument without '=VALUE'")
+	}
+
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if entry.Key == "" {
+			return nil, setInputErrorf("", "key name cannot be empty")
+		}
+		if entry.Value == "" {
+			return nil, setInputErrorf(entry.Key, "value for '%s' cannot be empty", entry.Key)
+		}
+		if err := domain.ValidateKeyName(entry.Key); err != nil {
+			return nil, &SetInputError{Key: entry.Key, Err: fmt.Errorf("invalid key name: %w", err)}
+		}
+		if seen[entry.Key] {
+			return nil, setInputErrorf(entry.Key, "key '%s' given more than once", entry.Key)
+		}
+		seen[entry.Key] = true
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"envy/internal/domain"
+)
+
+const (
+	// PublicKeyPrefix starts every public key, so they can be told apart
+	// from other strings and found in files
+	PublicKeyPrefix = "envypub1"
+
+	// bundleVersion is the format version SealBundle writes
+	bundleVersion = 1
+)
+
+// Identity is a user's sharing identity: an X25519 key that bundles are
+// encrypted to and an Ed25519 key that signs the bundles they send. Both are
+// derived from a single 32-byte seed, which is all that needs storing.
+type Identity struct {
+	Name     string
+	exchange *ecdh.PrivateKey
+	signing  ed25519.PrivateKey
+}
+
+// PublicKey is the public half of an Identity
+type PublicKey struct {
+	Exchange []byte
+	Signing  ed25519.PublicKey
+}
+
+// NewIdentity creates an identity from a new random seed and returns both
+func NewIdentity(name string) (Identity, []byte, error) {
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return Identity{}, nil, fmt.Errorf("failed to generate identity: %w", err)
+	}
+	identity, err := IdentityFromSeed(name, seed)
+	return identity, seed, err
+}
+
+// IdentityFromSeed rebuilds the identity derived from seed
+func IdentityFromSeed(name string, seed []byte) (Identity, error) {
+	if len(seed) != 32 {
+		return Identity{}, errors.New("invalid identity seed")
+	}
+
+	exchangeKey, err := hkdf.Key(sha256.New, seed, nil, "envy identity x25519", 32)
+	if err != nil {
+		return Identity{}, err
+	}
+	exchange, err := ecdh.X25519().NewPrivateKey(exchangeKey)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	signingSeed, err := hkdf.Key(sha256.New, seed, nil, "envy identity ed25519", ed25519.SeedSize)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	return Identity{Name: name, exchange: exchange, signing: ed25519.NewKeyFromSeed(signingSeed)}, nil
+}
+
+// PublicKey returns the key others share with this identity
+func (id Identity) PublicKey() PublicKey {
+	return PublicKey{
+		Exchange: id.exchange.PublicKey().Bytes(),
+		Signing:  id.signing.Public().(ed25519.PublicKey),
+	}
+}
+
+// String encodes the key as PublicKeyPrefix followed by both keys in
+// unpadded base64url
+func (p PublicKey) String() string {
+	return PublicKeyPrefix + base64.RawURLEncoding.EncodeToString(append(append([]byte{}, p.Exchange...), p.Signing...))
+}
+
+// Fingerprint is a short digest of the key for comparing it by eye or over
+// the phone
+func (p PublicKey) Fingerprint() string {
+	sum := sha256.Sum256([]byte(p.String()))
+	digest := hex.EncodeToString(sum[:8])
+	return strings.Join([]string{digest[0:4], digest[4:8], digest[8:12], digest[12:16]}, " ")
+}
+
+// ParsePublicKey reads a key written by PublicKey.String
+func ParsePublicKey(s string) (PublicKey, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, PublicKeyPrefix) {
+		return PublicKey{}, fmt.Errorf("not an Envy public key (they start with %s)", PublicKeyPrefix)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, PublicKeyPrefix))
+	if err != nil || len(raw) != 32+ed25519.PublicKeySize {
+		return PublicKey{}, errors.New("malformed Envy public key")
+	}
+	return PublicKey{Exchange: raw[:32], Signing: ed25519.PublicKey(raw[32:])}, nil
+}
+
+// SharedProject is the content of a bundle: a project's current values
+type SharedProject struct {
+	Project     string              `json:"project"`
+	Environment string              `json:"environment"`
+	Keys        []ImportedKey       `json:"keys"`
+	Layout      []domain.LayoutLine `json:"layout,omitempty"`
+}
+
+// BundleSender records who created a bundle
+type BundleSender struct {
+	Name      string `json:"name"`
+	PublicKey string `json:"public_key"`
+}
+
+// Bundle is a SharedProject encrypted to one recipient and signed by its
+// sender. The content is encrypted with AES-256-GCM under a key agreed
+// between a one-off X25519 key and the recipient's; the header is
+// authenticated with it, and the signature covers everything.
+type Bundle struct {
+	Version    int          `json:"version"`
+	Sender     BundleSender `json:"sender"`
+	Recipient  string       `json:"recipient"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ExpiresAt  time.Time    `json:"expires_at"`
+	Ephemeral  []byte       `json:"ephemeral"`
+	Ciphertext []byte       `json:"ciphertext,omitempty"`
+	Signature  []byte       `json:"signature,omitempty"`
+}
+
+// SealBundle encrypts project to recipient, valid from now for ttl
+func SealBundle(sender Identity, recipient PublicKey, project SharedProject, now time.Time, ttl time.Duration) (Bundle, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return Bundle{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	now = now.UTC().Truncate(time.Second)
+	bundle := Bundle{
+		Version:   bundleVersion,
+		Sender:    BundleSender{Name: sender.Name, PublicKey: sender.PublicKey().String()},
+		Recipient: recipient.String(),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		Ephemeral: ephemeral.PublicKey().Bytes(),
+	}
+
+	shared, err := agree(ephemeral, recipient.Exchange)
+	if err != nil {
+		return Bundle{}, err
+	}
+	aead, err := bundleCipher(shared, bundle.Ephemeral, recipient.Exchange)
+	if err != nil {
+		return Bundle{}, err
+	}
+	plaintext, err := json.Marshal(project)
+	if err != nil {
+		return Bundle{}, err
+	}
+
+	// The content key is used once, so a zero nonce is safe
+	nonce := make([]byte, aead.NonceSize())
+	bundle.Ciphertext = aead.Seal(nil, nonce, plaintext, bundle.header())
+	bundle.Signature = ed25519.Sign(sender.signing, bundle.signedData())
+	return bundle, nil
+}
+
+// OpenBundle checks that bundle was made for recipient, is signed by its
+// sender and hasn't expired at now, and decrypts it
+func OpenBundle(bundle Bundle, recipient Identity, now time.Time) (SharedProject, error) {
+	if bundle.Version != bundleVersion {
+		return SharedProject{}, fmt.Errorf("unsupported bundle version %d", bundle.Version)
+	}
+	if bundle.Recipient != recipient.PublicKey().String() {
+		return SharedProject{}, errors.New("bundle was made for another identity")
+	}
+
+	sender, err := ParsePublicKey(bundle.Sender.PublicKey)
+	if err != nil {
+		return SharedProject{}, fmt.Errorf("invalid sender: %w", err)
+	}
+	if !ed25519.Verify(sender.Signing, bundle.signedData(), bundle.Signature) {
+		return SharedProject{}, errors.New("bundle signature doesn't match its sender, it may have been tampered with")
+	}
+	if now.After(bundle.ExpiresAt) {
+		return SharedProject{}, fmt.Errorf("bundle expired on %s", bundle.ExpiresAt.Local().Format("2006-01-02 15:04"))
+	}
+
+	shared, err := agree(recipient.exchange, bundle.Ephemeral)
+	if err != nil {
+		return SharedProject{}, fmt.Errorf("invalid bundle: %w", err)
+	}
+	aead, err := bundleCipher(shared, bundle.Ephemeral, recipient.exchange.PublicKey().Bytes())
+	if err != nil {
+		return SharedProject{}, err
+	}
+	plaintext, err := aead.Open(nil, make([]byte, aead.NonceSize()), bundle.Ciphertext, bundle.header())
+	if err != nil {
+		return SharedProject{}, errors.New("failed to decrypt bundle")
+	}
+
+	var project SharedProject
+	if err := json.Unmarshal(plaintext, &project); err != nil {
+		return SharedProject{}, fmt.Errorf("invalid bundle content: %w", err)
+	}
+	return project, nil
+}
+
+// agree returns the X25519 shared secret of private and peer
+func agree(private *ecdh.PrivateKey, peer []byte) ([]byte, error) {
+	peerKey, err := ecdh.X25519().NewPublicKey(peer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	return private.ECDH(peerKey)
+}
+
+// bundleCipher derives the content key from the shared secret. Both public
+// keys go into the salt, binding the key to this pair.
+func bundleCipher(shared, ephemeral, recipient []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte{}, ephemeral...), recipient...)
+	key, err := hkdf.Key(sha256.New, shared, salt, "envy bundle v1", 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// header is the bundle without its content or signature
+func (b Bundle) header() []byte {
+	b.Ciphertext, b.Signature = nil, nil
+	data, _ := json.Marshal(b)
+	return data
+}
+
+// signedData is the bundle without its signature
+func (b Bundle) signedData() []byte {
+	b.Signature = nil
+	data, _ := json.Marshal(b)
+	return data
+}
+
+// ParseBundle reads a bundle file
+func ParseBundle(data []byte) (Bundle, error) {
+	var bundle Bundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return Bundle{}, fmt.Errorf("not an Envy bundle: %w", err)
+	}
+	if bundle.Version == 0 || len(bundle.Ciphertext) == 0 {
+		return Bundle{}, errors.New("not an Envy bundle")
+	}
+	return bundle, nil
+}
@@ -3,6 +3,7 @@ package service
 
 import (
 	"fmt"
+	"slices"
 	"time"
 
 	"envy/internal/domain"
@@ -20,6 +21,7 @@ type VaultService interface {
 
 	AddKey(projectName, projectEnv string, key domain.APIKey) error
 	UpdateKey(projectName, projectEnv, keyName string, newValue string) error
+	RollbackKey(projectName, projectEnv, keyName string, version int) error
 	DeleteKey(projectName, projectEnv, keyName string) error
 
 	Save() error
@@ -50,7 +52,7 @@ func (v *vaultService) GetProject(name, env string) (*domain.Project, error) {
 			return &v.projects[i], nil
 		}
 	}
-	return nil, fmt.Errorf("project '%s' (%s) not found", name, env)
+	return nil, fmt.Errorf("project '%s' (%s) %w", name, env, domain.ErrNotFound)
 }
 
 func (v *vaultService) CreateProject(project domain.Project) error {
@@ -84,7 +86,7 @@ func (v *vaultService) UpdateProject(project domain.Project) error {
 			return nil
 		}
 	}
-	return fmt.Errorf("project '%s' (%s) not found", project.Name, project.Environment)
+	return fmt.Errorf("project '%s' (%s) %w", project.Name, project.Environment, domain.ErrNotFound)
 }
 
 func (v *vaultService) DeleteProject(name, env string) error {
@@ -95,7 +97,7 @@ func (v *vaultService) DeleteProject(name, env string) error {
 			return nil
 		}
 	}
-	return fmt.Errorf("project '%s' (%s) not found", name, env)
+	return fmt.Errorf("project '%s' (%s) %w", name, env, domain.ErrNotFound)
 }
 
 func (v *vaultService) AddKey(projectName, projectEnv string, key domain.APIKey) error {
@@ -137,7 +139,36 @@ func (v *vaultService) UpdateKey(projectName, projectEnv, keyName string, newVal
 		}
 	}
 
-	return fmt.Errorf("key '%s' not found in project '%s' (%s)", keyName, projectName, projectEnv)
+	return fmt.Errorf("key '%s' %w in project '%s' (%s)", keyName, domain.ErrNotFound, projectName, projectEnv)
+}
+
+// RollbackKey makes history version (1-based, oldest first) the current value again.
+// Like UpdateKey the present value is pushed into history, so a rollback can itself be undone.
+func (v *vaultService) RollbackKey(projectName, projectEnv, keyName string, version int) error {
+	project, err := v.GetProject(projectName, projectEnv)
+	if err != nil {
+		return err
+	}
+
+	for i, key := range project.Keys {
+		if key.Key == keyName {
+			if version < 1 || version > len(key.History) {
+				return fmt.Errorf("version %d %w for key '%s' (history has %d versions)", version, domain.ErrNotFound, keyName, len(key.History))
+			}
+
+			restored := key.History[version-1].Value
+			project.Keys[i].History = append(project.Keys[i].History, key.Current)
+
+			project.Keys[i].Current = domain.SecretVersion{
+				Value:     restored,
+				CreatedAt: time.Now(),
+				CreatedBy: "cli-rollback",
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("key '%s' %w in project '%s' (%s)", keyName, domain.ErrNotFound, projectName, projectEnv)
 }
 
 func (v *vaultService) DeleteKey(projectName, projectEnv, keyName string) error {
@@ -148,13 +179,12 @@ func (v *vaultService) DeleteKey(projectName, projectEnv, keyName string) error
 
 	for i, key := range project.Keys {
 		if key.Key == keyName {
-			project.Keys[i] = project.Keys[len(project.Keys)-1]
-			project.Keys = project.Keys[:len(project.Keys)-1]
+			project.Keys = slices.Delete(project.Keys, i, i+1)
 			return nil
 		}
 	}
 
-	return fmt.Errorf("key '%s' not found in project '%s' (%s)", keyName, projectName, projectEnv)
+	return fmt.Errorf("key '%s' %w in project '%s' (%s)", keyName, domain.ErrNotFound, projectName, projectEnv)
 }
 
 // Save persists all projects to storage
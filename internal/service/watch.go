@@ -0,0 +1,60 @@
+package service
+
+import (
+	"maps"
+	"os"
+	"time"
+)
+
+// ReloadDecision is what 'envy run --watch' does with the running command
+// after a save to the vault
+type ReloadDecision int
+
+const (
+	// KeepUnchanged keeps the command, its secrets are the same
+	KeepUnchanged ReloadDecision = iota
+	// KeepFailed keeps the command, the saved vault couldn't be used
+	KeepFailed
+	// Restart stops the command and starts it with the new secrets
+	Restart
+)
+
+// DecideReload compares the secrets the command runs with to the ones read
+// from the saved vault. err is the error reading them, in which case the
+// running command is kept rather than stopped with nothing to replace it.
+func DecideReload(current, next map[string]string, err error) ReloadDecision {
+	switch {
+	case err != nil:
+		return KeepFailed
+	case maps.Equal(current, next):
+		return KeepUnchanged
+	}
+	return Restart
+}
+
+// Process is the part of *os.Process needed to stop a command
+type Process interface {
+	Signal(sig os.Signal) error
+	Kill() error
+}
+
+// StopProcess sends sig to process and waits for done, which receives once
+// the process exited. A process still running after grace is killed. It
+// reports whether the process had to be killed.
+func StopProcess(process Process, done <-chan error, sig os.Signal, grace time.Duration) bool {
+	if err := process.Signal(sig); err != nil {
+		process.Kill()
+	}
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return false
+	case <-timer.C:
+		process.Kill()
+		<-done
+		return true
+	}
+}
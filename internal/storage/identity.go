@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"envy/internal/crypto"
+	"envy/internal/domain"
+)
+
+// ErrNoIdentity is returned by LoadIdentity before 'envy identity create'
+var ErrNoIdentity = fmt.Errorf("identity %w. Run 'envy identity create' first", domain.ErrNotFound)
+
+// IdentityFile is the sharing identity of the vault's owner. The seed it is
+// derived from is encrypted with the vault key; the rest is public.
+type IdentityFile struct {
+	Name      string    `json:"name"`
+	PublicKey string    `json:"public_key"`
+	Seed      string    `json:"seed"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IdentityPath is where the identity is stored, next to the vault
+func IdentityPath() string {
+	return filepath.Join(filepath.Dir(getStorePath()), "identity.json")
+}
+
+// SaveIdentity encrypts seed with key and writes the identity, replacing any
+// existing one
+func SaveIdentity(name, publicKey string, seed, key []byte) error {
+	encryptedSeed, err := crypto.Encrypt(seed, key)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt identity: %w", err)
+	}
+
+	data, err := json.MarshalIndent(IdentityFile{
+		Name:      name,
+		PublicKey: publicKey,
+		Seed:      encryptedSeed,
+		CreatedAt: time.Now().UTC(),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal identity: %w", err)
+	}
+
+	path := IdentityPath()
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+	return nil
+}
+
+// LoadIdentity reads the identity without decrypting its seed
+func LoadIdentity() (IdentityFile, error) {
+	var identity IdentityFile
+
+	data, err := os.ReadFile(IdentityPath())
+	if os.IsNotExist(err) {
+		return identity, ErrNoIdentity
+	}
+	if err != nil {
+		return identity, fmt.Errorf("failed to read identity: %w", err)
+	}
+	if err := json.Unmarshal(data, &identity); err != nil {
+		return identity, fmt.Errorf("failed to parse identity: %w", err)
+	}
+	return identity, nil
+}
+
+// DecryptSeed returns the identity's seed, using the vault key
+func (f IdentityFile) DecryptSeed(key []byte) ([]byte, error) {
+	seed, err := crypto.Decrypt(f.Seed, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt identity: %w", err)
+	}
+	return seed, nil
+}
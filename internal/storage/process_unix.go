@@ -0,0 +1,40 @@
+//go:build unix
+
+package storage
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// processAlive reports whether a process with this pid exists
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// checkPrivateDir makes sure path is a real directory only the current user
+// can access. Shared locations like /dev/shm could otherwise be prepared by
+// another user.
+func checkPrivateDir(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("failed to check runtime directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("runtime directory %s is not a directory", path)
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if ok && int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("runtime directory %s is owned by another user", path)
+	}
+
+	if info.Mode().Perm()&0o077 != 0 {
+		if err := os.Chmod(path, 0o700); err != nil {
+			return fmt.Errorf("failed to restrict runtime directory: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"envy/internal/config"
+)
+
+const (
+	secretDirPrefix = "run-"
+	ownerFileName   = ".owner"
+
+	// A directory without an owner file is only swept once it is this old,
+	// so a run that is still setting up isn't disturbed
+	sweepGracePeriod = time.Minute
+)
+
+// SecretDir is a private directory holding secrets written to files for the
+// lifetime of one command
+type SecretDir struct {
+	path string
+}
+
+// CreateSecretDir makes a new directory under the runtime directory and
+// records the current process as its owner. Directories left behind by
+// processes that no longer exist are removed first.
+func CreateSecretDir() (*SecretDir, error) {
+	base := config.GetRuntimeDir()
+	if err := os.MkdirAll(base, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create runtime directory: %w", err)
+	}
+	if err := checkPrivateDir(base); err != nil {
+		return nil, err
+	}
+
+	SweepSecretDirs()
+
+	path, err := os.MkdirTemp(base, secretDirPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secrets directory: %w", err)
+	}
+	dir := &SecretDir{path: path}
+
+	owner := []byte(strconv.Itoa(os.Getpid()))
+	if err := os.WriteFile(filepath.Join(path, ownerFileName), owner, 0o600); err != nil {
+		dir.Remove()
+		return nil, fmt.Errorf("failed to create secrets directory: %w", err)
+	}
+
+	return dir, nil
+}
+
+// WriteFile stores value in a new 0600 file named after name and returns its path
+func (d *SecretDir) WriteFile(name, value string) (string, error) {
+	path := filepath.Join(d.path, secretFileName(name))
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file for %s: %w", name, err)
+	}
+	if _, err := file.WriteString(value); err != nil {
+		file.Close()
+		return "", fmt.Errorf("failed to write file for %s: %w", name, err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write file for %s: %w", name, err)
+	}
+
+	return path, nil
+}
+
+// Remove overwrites every file with zeros and deletes the directory
+func (d *SecretDir) Remove() error {
+	entries, _ := os.ReadDir(d.path)
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			shredFile(filepath.Join(d.path, entry.Name()))
+		}
+	}
+	return os.RemoveAll(d.path)
+}
+
+// SweepSecretDirs removes secrets directories whose owning process is gone,
+// such as those of a run that was killed or crashed
+func SweepSecretDirs() {
+	base := config.GetRuntimeDir()
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), secretDirPrefix) {
+			continue
+		}
+		dir := &SecretDir{path: filepath.Join(base, entry.Name())}
+		if !dir.abandoned() {
+			continue
+		}
+		dir.Remove()
+	}
+}
+
+func (d *SecretDir) abandoned() bool {
+	data, err := os.ReadFile(filepath.Join(d.path, ownerFileName))
+	if err != nil {
+		info, err := os.Stat(d.path)
+		return err == nil && time.Since(info.ModTime()) > sweepGracePeriod
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	return err != nil || !processAlive(pid)
+}
+
+func shredFile(path string) {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		file.Write(make([]byte, info.Size()))
+		file.Sync()
+	}
+}
+
+// secretFileName turns a variable name into a safe file name
+func secretFileName(name string) string {
+	safe := []byte(name)
+	for i, c := range safe {
+		isAlnum := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+		if !isAlnum && c != '_' && c != '-' && c != '.' {
+			safe[i] = '_'
+		}
+	}
+	if len(safe) == 0 || safe[0] == '.' {
+		return "_" + string(safe)
+	}
+	return string(safe)
+}
@@ -4,6 +4,7 @@ package storage
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -17,6 +18,9 @@ const (
 	schemaVersion = 1
 )
 
+// ErrIncorrectPassword is returned by Load when the master password doesn't match the vault
+var ErrIncorrectPassword = errors.New("authentication failed: incorrect password")
+
 // Store configuration: set by main before use
 var storeConfig config.BackendConfig
 
@@ -91,8 +95,11 @@ func saveStore(store domain.Store) error {
 }
 
 func Load(password string) ([]domain.Project, []byte, error) {
-	path := getStorePath()
+	return LoadFile(getStorePath(), password)
+}
 
+// LoadFile decrypts the vault stored at path, such as a backup copy of keys.json.
+func LoadFile(path, password string) ([]domain.Project, []byte, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -114,7 +121,7 @@ func Load(password string) ([]domain.Project, []byte, error) {
 	key := crypto.DeriveKey(password, salt)
 
 	if !crypto.VerifyAuthHash(key, store.AuthHash) {
-		return nil, nil, fmt.Errorf("authentication failed: incorrect password")
+		return nil, nil, ErrIncorrectPassword
 	}
 
 	decryptedProjects, err := decryptSecrets(store.Projects, key)
@@ -125,6 +132,69 @@ func Load(password string) ([]domain.Project, []byte, error) {
 	return decryptedProjects, key, nil
 }
 
+// LoadWithKey decrypts the vault again with a key returned by an earlier
+// Load, without asking for the password. It fails with ErrIncorrectPassword
+// if the master password was changed in the meantime.
+func LoadWithKey(key []byte) ([]domain.Project, error) {
+	data, err := os.ReadFile(getStorePath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage file: %w", err)
+	}
+
+	var store domain.Store
+	if err := json.Unmarshal(data, &store); err != nil {
+		return nil, fmt.Errorf("failed to parse storage file (corrupted?): %w", err)
+	}
+
+	if !crypto.VerifyAuthHash(key, store.AuthHash) {
+		return nil, ErrIncorrectPassword
+	}
+
+	decryptedProjects, err := decryptSecrets(store.Projects, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets: %w", err)
+	}
+	return decryptedProjects, nil
+}
+
+// VaultPath returns the location of the vault file in use
+func VaultPath() string {
+	return getStorePath()
+}
+
+// LoadMetadata returns project names, environments and key names without
+// asking for the password. Names are stored unencrypted, values are never
+// decrypted and are blanked in the result. Used for shell completion.
+func LoadMetadata() ([]domain.Project, error) {
+	data, err := os.ReadFile(getStorePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []domain.Project{}, nil
+		}
+		return nil, fmt.Errorf("failed to read storage file: %w", err)
+	}
+
+	var store domain.Store
+	if err := json.Unmarshal(data, &store); err != nil {
+		return nil, fmt.Errorf("failed to parse storage file (corrupted?): %w", err)
+	}
+
+	projects := make([]domain.Project, len(store.Projects))
+	for i, project := range store.Projects {
+		keys := make([]domain.APIKey, len(project.Keys))
+		for j, apiKey := range project.Keys {
+			keys[j] = domain.APIKey{Title: apiKey.Title, Key: apiKey.Key}
+		}
+		projects[i] = domain.Project{
+			Name:        project.Name,
+			Environment: project.Environment,
+			Keys:        keys,
+		}
+	}
+
+	return projects, nil
+}
+
 func Save(projects []domain.Project, key []byte) error {
 	lockPath := getLockPath()
 	if err := os.MkdirAll(filepath.Dir(lockPath), 0o700); err != nil {
@@ -191,9 +261,16 @@ func encryptSecrets(projects []domain.Project, key []byte) ([]domain.Project, er
 				}
 			}
 
+			encryptedDescription, err := encryptText(apiKey.Description, key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encrypt description of %s.%s: %w",
+					project.Name, apiKey.Key, err)
+			}
+
 			encryptedKeys[j] = domain.APIKey{
-				Title: apiKey.Title,
-				Key:   apiKey.Key,
+				Title:       apiKey.Title,
+				Key:         apiKey.Key,
+				Description: encryptedDescription,
 				Current: domain.SecretVersion{
 					Value:     encryptedCurrent,
 					CreatedAt: apiKey.Current.CreatedAt,
@@ -203,10 +280,18 @@ func encryptSecrets(projects []domain.Project, key []byte) ([]domain.Project, er
 			}
 		}
 
+		encryptedLayout, err := mapLayoutText(project.Layout, func(text string) (string, error) {
+			return encryptText(text, key)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt layout of %s: %w", project.Name, err)
+		}
+
 		encrypted[i] = domain.Project{
 			Name:        project.Name,
 			Environment: project.Environment,
 			Keys:        encryptedKeys,
+			Layout:      encryptedLayout,
 		}
 	}
 
@@ -240,9 +325,16 @@ func decryptSecrets(projects []domain.Project, key []byte) ([]domain.Project, er
 				}
 			}
 
+			decryptedDescription, err := decryptText(apiKey.Description, key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt description of %s.%s: %w",
+					project.Name, apiKey.Key, err)
+			}
+
 			decryptedKeys[j] = domain.APIKey{
-				Title: apiKey.Title,
-				Key:   apiKey.Key,
+				Title:       apiKey.Title,
+				Key:         apiKey.Key,
+				Description: decryptedDescription,
 				Current: domain.SecretVersion{
 					Value:     string(decryptedCurrent),
 					CreatedAt: apiKey.Current.CreatedAt,
@@ -252,16 +344,57 @@ func decryptSecrets(projects []domain.Project, key []byte) ([]domain.Project, er
 			}
 		}
 
+		decryptedLayout, err := mapLayoutText(project.Layout, func(text string) (string, error) {
+			return decryptText(text, key)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt layout of %s: %w", project.Name, err)
+		}
+
 		decrypted[i] = domain.Project{
 			Name:        project.Name,
 			Environment: project.Environment,
 			Keys:        decryptedKeys,
+			Layout:      decryptedLayout,
 		}
 	}
 
 	return decrypted, nil
 }
 
+// encryptText encrypts descriptions and comments, which can hold as much as
+// a value does, e.g. a commented-out old key. Empty text stays empty.
+func encryptText(text string, key []byte) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+	return crypto.Encrypt([]byte(text), key)
+}
+
+func decryptText(text string, key []byte) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+	plaintext, err := crypto.Decrypt(text, key)
+	return string(plaintext), err
+}
+
+// mapLayoutText returns a copy of layout with convert applied to every comment
+func mapLayoutText(layout []domain.LayoutLine, convert func(string) (string, error)) ([]domain.LayoutLine, error) {
+	if layout == nil {
+		return nil, nil
+	}
+	converted := make([]domain.LayoutLine, len(layout))
+	for i, line := range layout {
+		text, err := convert(line.Text)
+		if err != nil {
+			return nil, err
+		}
+		converted[i] = domain.LayoutLine{Key: line.Key, Text: text}
+	}
+	return converted, nil
+}
+
 func saveStoreUnlocked(store domain.Store) error {
 	path := getStorePath()
 
@@ -0,0 +1,229 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"envy/internal/domain"
+	"envy/internal/service"
+)
+
+func TestDetectCIProvider(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want string
+	}{
+		{map[string]string{"GITHUB_ACTIONS": "true", "CI": "true"}, "github"},
+		{map[string]string{"GITLAB_CI": "true", "CI": "true"}, "gitlab"},
+		{map[string]string{"CI": "true"}, "generic"},
+		{map[string]string{}, "generic"},
+	}
+	for _, tt := range tests {
+		getenv := func(name string) string { return tt.env[name] }
+		if got := service.DetectCIProvider(getenv); got != tt.want {
+			t.Errorf("DetectCIProvider(%v) = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestInCI(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want bool
+	}{
+		{map[string]string{"CI": "true"}, true},
+		{map[string]string{"GITLAB_CI": "true"}, true},
+		{map[string]string{"GITHUB_ACTIONS": "true"}, true},
+		{map[string]string{"CI": ""}, false},
+		{map[string]string{}, false},
+	}
+	for _, tt := range tests {
+		getenv := func(name string) string { return tt.env[name] }
+		if got := service.InCI(getenv); got != tt.want {
+			t.Errorf("InCI(%v) = %v, want %v", tt.env, got, tt.want)
+		}
+	}
+}
+
+// parseGitHubEnv reads a $GITHUB_ENV file the way the runner does
+func parseGitHubEnv(t *testing.T, data string) map[string]string {
+	t.Helper()
+	vars := make(map[string]string)
+	lines := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		if key, delimiter, ok := strings.Cut(lines[i], "<<"); ok {
+			var value []string
+			for i++; i < len(lines) && lines[i] != delimiter; i++ {
+				value = append(value, lines[i])
+			}
+			if i == len(lines) {
+				t.Fatalf("heredoc for %s isn't closed", key)
+			}
+			vars[key] = strings.Join(value, "\n")
+			continue
+		}
+		key, value, _ := strings.Cut(lines[i], "=")
+		vars[key] = value
+	}
+	return vars
+}
+
+func TestFormatCIExportGitHub(t *testing.T) {
+	project := domain.Project{Name: "myapp", Environment: "prod", Keys: []domain.APIKey{
+		{Key: "API_KEY", Current: domain.SecretVersion{Value: "sk_live_1=2"}},
+		{Key: "CERT", Current: domain.SecretVersion{Value: "-----BEGIN-----\nabc%def\n\n-----END-----\n"}},
+		{Key: "SAME", Current: domain.SecretVersion{Value: "sk_live_1=2"}},
+		{Key: "EMPTY"},
+	}}
+
+	export, err := service.FormatCIExport("github", "", project)
+	if err != nil {
+		t.Fatalf("FormatCIExport: %v", err)
+	}
+
+	wantLog := "::add-mask::sk_live_1=2\n" +
+		"::add-mask::-----BEGIN-----\n" +
+		"::add-mask::abc%25def\n" +
+		"::add-mask::-----END-----\n"
+	if string(export.Log) != wantLog {
+		t.Errorf("log = %q, want %q", export.Log, wantLog)
+	}
+	if export.Masked != 4 {
+		t.Errorf("Masked = %d, want 4", export.Masked)
+	}
+	if len(export.Unmasked) != 0 {
+		t.Errorf("Unmasked = %q, want every value masked", export.Unmasked)
+	}
+
+	vars := parseGitHubEnv(t, string(export.Env))
+	for _, k := range project.Keys {
+		if vars[k.Key] != k.Current.Value {
+			t.Errorf("%s = %q, want %q", k.Key, vars[k.Key], k.Current.Value)
+		}
+	}
+}
+
+func TestFormatCIExportShell(t *testing.T) {
+	project := domain.Project{Name: "myapp", Environment: "prod", Keys: []domain.APIKey{
+		{Key: "API_KEY", Current: domain.SecretVersion{Value: "it's"}},
+	}}
+
+	for _, provider := range []string{"gitlab", "generic"} {
+		export, err := service.FormatCIExport(provider, "", project)
+		if err != nil {
+			t.Fatalf("%s: %v", provider, err)
+		}
+		if len(export.Log) != 0 {
+			t.Errorf("%s: log = %q, want nothing", provider, export.Log)
+		}
+		if export.Masked != 0 || len(export.Unmasked) != 1 || export.Unmasked[0] != "API_KEY" {
+			t.Errorf("%s: masked %d, unmasked %q, want API_KEY unmasked", provider, export.Masked, export.Unmasked)
+		}
+		if !strings.Contains(string(export.Env), `export API_KEY='it'\''s'`) {
+			t.Errorf("%s: env = %q", provider, export.Env)
+		}
+	}
+}
+
+func TestFormatCIExportErrors(t *testing.T) {
+	project := domain.Project{Name: "myapp", Environment: "prod", Keys: []domain.APIKey{
+		{Key: "app.port", Current: domain.SecretVersion{Value: "8080"}},
+	}}
+	if _, err := service.FormatCIExport("github", "", project); err == nil {
+		t.Error("key that isn't a variable name accepted")
+	}
+	if _, err := service.FormatCIExport("jenkins", "", domain.Project{}); err == nil {
+		t.Error("unknown provider accepted")
+	}
+}
+
+func TestFormatCIExportDotenvReport(t *testing.T) {
+	project := domain.Project{Name: "myapp", Environment: "prod", Keys: []domain.APIKey{
+		{Key: "API_KEY", Current: domain.SecretVersion{Value: "it's"}},
+		{Key: "URL", Current: domain.SecretVersion{Value: "https://x?a=1&b=2"}},
+	}}
+
+	export, err := service.FormatCIExport("gitlab", "dotenv", project)
+	if err != nil {
+		t.Fatalf("FormatCIExport: %v", err)
+	}
+	if want := "API_KEY=it's\nURL=https://x?a=1&b=2\n"; string(export.Env) != want {
+		t.Errorf("env = %q, want %q", export.Env, want)
+	}
+
+	project.Keys[0].Current.Value = "line1\nline2"
+	if _, err := service.FormatCIExport("gitlab", "dotenv", project); err == nil {
+		t.Error("multiline value accepted in a dotenv report")
+	}
+	if _, err := service.FormatCIExport("github", "dotenv", project); err == nil {
+		t.Error("format accepted for github")
+	}
+	if _, err := service.FormatCIExport("generic", "toml", project); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestCIExportCommand(t *testing.T) {
+	home := testVault(t, "password123", []domain.Project{createTestProject("myapp", "prod", "API_KEY")})
+	password := "ENVY_MASTER_PASSWORD=password123"
+
+	// Stdout is refused in CI unless asked for
+	for _, ci := range []string{"CI=true", "GITLAB_CI=true"} {
+		res := runEnvy(t, home, home, []string{password, ci}, "ci", "export", "myapp:prod", "--provider", "gitlab")
+		if res.code != 2 || res.stdout != "" || !strings.Contains(res.stderr, "--stdout") {
+			t.Errorf("%s without -o: exit %d, stdout %q, stderr %q", ci, res.code, res.stdout, res.stderr)
+		}
+	}
+
+	res := runEnvy(t, home, home, []string{password, "CI=true"}, "ci", "export", "myapp:prod", "--provider", "generic", "--stdout")
+	if res.code != 0 || !strings.Contains(res.stdout, "export API_KEY='secret-API_KEY'") {
+		t.Errorf("--stdout: exit %d, stdout %q, stderr %q", res.code, res.stdout, res.stderr)
+	}
+
+	// Outside CI printing for eval stays the default
+	res = runEnvy(t, home, home, []string{password}, "ci", "export", "myapp:prod", "--provider", "generic")
+	if res.code != 0 || !strings.Contains(res.stdout, "export API_KEY=") {
+		t.Errorf("outside CI: exit %d, stdout %q, stderr %q", res.code, res.stdout, res.stderr)
+	}
+
+	res = runEnvy(t, home, home, []string{password, "GITLAB_CI=true"},
+		"ci", "export", "myapp:prod", "--format", "dotenv", "-o", "deploy.env")
+	if res.code != 0 || res.stdout != "" {
+		t.Fatalf("-o: exit %d, stdout %q, stderr %q", res.code, res.stdout, res.stderr)
+	}
+	path := filepath.Join(home, "deploy.env")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "API_KEY=secret-API_KEY\n" {
+		t.Errorf("dotenv report = %q", data)
+	}
+	if !strings.Contains(res.stderr, "Warning: API_KEY can't be masked by gitlab") {
+		t.Errorf("no warning about the unmasked key: %q", res.stderr)
+	}
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Errorf("dotenv report mode = %v, want 0600", info.Mode().Perm())
+		}
+	}
+
+	for _, args := range [][]string{
+		{"--stdout", "-o", "out.sh"},
+		{"-o", "-"},
+		{"--format", "toml", "-o", "out.sh"},
+		{"--provider", "github", "--stdout"},
+	} {
+		res := runEnvy(t, home, home, []string{password}, append([]string{"ci", "export", "myapp:prod", "--provider", "generic"}, args...)...)
+		if res.code != 2 || res.stdout != "" {
+			t.Errorf("%v: exit %d, stdout %q", args, res.code, res.stdout)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+//go:build linux
+
+package tests
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// runEnvyWithPassword runs envy like runEnvy, but on a terminal of its own
+// where password is typed at the master password prompt
+func runEnvyWithPassword(t *testing.T, home, dir string, env []string, password string, args ...string) envyResult {
+	t.Helper()
+	master, terminal := openTerminal(t)
+	defer master.Close()
+
+	cmd := envyCommand(t, home, dir, env, args...)
+	cmd.Stdin = terminal
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start envy: %v", err)
+	}
+	terminal.Close()
+
+	if _, err := master.Write([]byte(password + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	// Keep reading the echo, so the terminal's buffer never fills
+	go io.Copy(io.Discard, master)
+	return finishEnvy(t, cmd, cmd.Wait())
+}
+
+// openTerminal opens a new pseudo-terminal, returning its master side and
+// the terminal itself
+func openTerminal(t *testing.T) (master, terminal *os.File) {
+	t.Helper()
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("no pseudo-terminals: %v", err)
+	}
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		t.Fatalf("unlock terminal: %v", err)
+	}
+	n, err := unix.IoctlGetUint32(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		t.Fatalf("terminal number: %v", err)
+	}
+	terminal, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		t.Fatalf("open terminal: %v", err)
+	}
+	return master, terminal
+}
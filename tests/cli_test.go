@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"envy/internal/config"
+	"envy/internal/domain"
+	"envy/internal/storage"
+)
+
+// Tests of whole commands run a real envy binary, built once
+var (
+	envyBuildDir  string
+	envyBuildOnce sync.Once
+	envyBinary    string
+	envyBuildErr  error
+
+	// buildEnv is the environment before tests change HOME, which the go
+	// command needs to find its caches
+	buildEnv []string
+)
+
+func TestMain(m *testing.M) {
+	buildEnv = os.Environ()
+	code := m.Run()
+	if envyBuildDir != "" {
+		os.RemoveAll(envyBuildDir)
+	}
+	os.Exit(code)
+}
+
+func buildEnvy(t *testing.T) string {
+	t.Helper()
+	envyBuildOnce.Do(func() {
+		if envyBuildDir, envyBuildErr = os.MkdirTemp("", "envy-test-"); envyBuildErr != nil {
+			return
+		}
+		envyBinary = filepath.Join(envyBuildDir, "envy")
+		if runtime.GOOS == "windows" {
+			envyBinary += ".exe"
+		}
+		build := exec.Command("go", "build", "-o", envyBinary, "envy/cmd")
+		build.Env = buildEnv
+		out, err := build.CombinedOutput()
+		if err != nil {
+			envyBuildErr = errors.New(string(out))
+		}
+	})
+	if envyBuildErr != nil {
+		t.Fatalf("failed to build envy: %v", envyBuildErr)
+	}
+	return envyBinary
+}
+
+// testVault creates a home directory holding a vault with projects, locked
+// with password
+func testVault(t *testing.T, password string, projects []domain.Project) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("APPDATA", home)
+	storage.SetConfig(config.BackendConfig{})
+
+	if err := os.MkdirAll(config.GetDefaultDataDir(), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Initialize(password); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	_, key, err := storage.Load(password)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := storage.Save(projects, key); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	return home
+}
+
+// envyResult is what a run of the envy binary printed and its exit code
+type envyResult struct {
+	stdout string
+	stderr string
+	code   int
+}
+
+// runEnvy runs envy in dir with home as its home directory, no terminal and
+// only the variables in env besides PATH
+func runEnvy(t *testing.T, home, dir string, env []string, args ...string) envyResult {
+	t.Helper()
+	cmd := envyCommand(t, home, dir, env, args...)
+	detachTerminal(cmd)
+	return finishEnvy(t, cmd, cmd.Run())
+}
+
+// envyCommand prepares a run of envy with its output captured
+func envyCommand(t *testing.T, home, dir string, env []string, args ...string) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command(buildEnvy(t), args...)
+	cmd.Dir = dir
+	cmd.Env = append([]string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + home,
+		"APPDATA=" + home,
+		"XDG_CONFIG_HOME=" + filepath.Join(home, ".config"),
+	}, env...)
+	cmd.Stdout, cmd.Stderr = &bytes.Buffer{}, &bytes.Buffer{}
+	return cmd
+}
+
+// finishEnvy collects what cmd printed once it returned err
+func finishEnvy(t *testing.T, cmd *exec.Cmd, err error) envyResult {
+	t.Helper()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatalf("failed to run envy: %v", err)
+	}
+	return envyResult{
+		stdout: cmd.Stdout.(*bytes.Buffer).String(),
+		stderr: cmd.Stderr.(*bytes.Buffer).String(),
+		code:   cmd.ProcessState.ExitCode(),
+	}
+}
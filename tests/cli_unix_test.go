@@ -0,0 +1,14 @@
+//go:build unix
+
+package tests
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachTerminal starts cmd in its own session, so prompts can't reach the
+// terminal the tests run in
+func detachTerminal(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
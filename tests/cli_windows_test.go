@@ -0,0 +1,17 @@
+//go:build windows
+
+package tests
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachedProcess starts a process without a console
+const detachedProcess = 0x00000008
+
+// detachTerminal starts cmd without a console, so prompts can't reach the
+// one the tests run in
+func detachTerminal(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: detachedProcess}
+}
@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"envy/internal/config"
+	"envy/internal/domain"
+	"envy/internal/service"
+)
+
+func completionVault() []domain.Project {
+	return []domain.Project{
+		createTestProject("myapp", "dev", "API_KEY", "DB_URL"),
+		createTestProject("myapp", "prod", "API_KEY", "STRIPE_KEY"),
+		createTestProject("MyTool", "dev", "TOKEN"),
+		createTestProject("web:legacy", "stage", "PORT"),
+	}
+}
+
+func TestProjectNameCandidates(t *testing.T) {
+	tests := []struct {
+		toComplete string
+		want       []string
+	}{
+		{"", []string{"MyTool\tdev", "myapp\tdev, prod", "web:legacy\tstage"}},
+		{"my", []string{"MyTool\tdev", "myapp\tdev, prod"}},
+		{"MYA", []string{"myapp\tdev, prod"}},
+		{"web:", []string{"web:legacy\tstage"}},
+		{"nope", []string{}},
+	}
+	for _, tt := range tests {
+		got := service.ProjectNameCandidates(completionVault(), tt.toComplete)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ProjectNameCandidates(%q) = %q, want %q", tt.toComplete, got, tt.want)
+		}
+	}
+
+	if got := service.ProjectNameCandidates(nil, ""); len(got) != 0 {
+		t.Errorf("no projects gave %q", got)
+	}
+}
+
+func TestKeyNameCandidates(t *testing.T) {
+	tests := []struct {
+		project, env, toComplete string
+		want                     []string
+	}{
+		{"myapp", "dev", "", []string{"API_KEY", "DB_URL"}},
+		{"myapp", "prod", "", []string{"API_KEY", "STRIPE_KEY"}},
+		{"myapp", "", "", []string{"API_KEY", "DB_URL", "STRIPE_KEY"}},
+		{"MYAPP", "prod", "S", []string{"STRIPE_KEY"}},
+		{"myapp", "prod", "s", nil},
+		{"myapp", "stage", "", nil},
+		{"unknown", "", "", nil},
+	}
+	for _, tt := range tests {
+		got := service.KeyNameCandidates(completionVault(), tt.project, tt.env, tt.toComplete)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("KeyNameCandidates(%q, %q, %q) = %q, want %q", tt.project, tt.env, tt.toComplete, got, tt.want)
+		}
+	}
+}
+
+func TestProjectSpecCandidates(t *testing.T) {
+	tests := []struct {
+		toComplete string
+		want       []string
+	}{
+		{"", []string{"MyTool:dev", "myapp:dev", "myapp:prod", "web:legacy:stage"}},
+		{"myapp:p", []string{"myapp:prod"}},
+		{"MYAPP:", []string{"myapp:dev", "myapp:prod"}},
+		{"x", nil},
+	}
+	for _, tt := range tests {
+		got := service.ProjectSpecCandidates(completionVault(), tt.toComplete)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ProjectSpecCandidates(%q) = %q, want %q", tt.toComplete, got, tt.want)
+		}
+	}
+}
+
+func TestParseProjectSpec(t *testing.T) {
+	tests := []struct {
+		spec, name, env string
+	}{
+		{"myapp", "myapp", ""},
+		{"myapp:prod", "myapp", "prod"},
+		{"web:legacy:stage", "web:legacy", "stage"},
+		{"db:5432", "db:5432", ""},
+		{"myapp:", "myapp:", ""},
+		{":prod", ":prod", ""},
+	}
+	for _, tt := range tests {
+		name, env := service.ParseProjectSpec(tt.spec)
+		if name != tt.name || env != tt.env {
+			t.Errorf("ParseProjectSpec(%q) = %q, %q, want %q, %q", tt.spec, name, env, tt.name, tt.env)
+		}
+	}
+}
+
+// complete asks the envy binary for completions of args
+func complete(t *testing.T, home string, args ...string) []string {
+	t.Helper()
+	res := runEnvy(t, home, home, nil, append([]string{"__complete"}, args...)...)
+	if res.code != 0 {
+		t.Fatalf("__complete %q exited %d: %s", args, res.code, res.stderr)
+	}
+	// The last line is the directive
+	lines := strings.Split(strings.TrimSpace(res.stdout), "\n")
+	return lines[:len(lines)-1]
+}
+
+func TestCompletionFromVault(t *testing.T) {
+	home := testVault(t, "password123", completionVault())
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"get", "my"}, []string{"MyTool\tdev", "myapp\tdev, prod"}},
+		{[]string{"get", "myapp:prod", ""}, []string{"API_KEY", "STRIPE_KEY"}},
+		{[]string{"get", "myapp", "-e", "dev", ""}, []string{"API_KEY", "DB_URL"}},
+		{[]string{"set", "myapp", "-e", "prod", "ST"}, []string{"STRIPE_KEY="}},
+		{[]string{"set", "myapp", "API_KEY=x"}, []string{}},
+		{[]string{"diff", "myapp:d"}, []string{"myapp:dev"}},
+		{[]string{"diff", "myapp", ""}, []string{"dev", "stage", "prod"}},
+		{[]string{"run", "myapp:p"}, []string{"myapp:prod"}},
+		{[]string{"run", "shared", "my"}, []string{"MyTool\tdev", "myapp\tdev, prod", "MyTool:dev", "myapp:dev", "myapp:prod"}},
+		{[]string{"run", "shared", "myapp:dev", "myapp:p"}, []string{"myapp:prod"}},
+		{[]string{"run", "myapp", "--", "my"}, []string{}},
+	}
+	for _, tt := range tests {
+		got := complete(t, home, tt.args...)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("complete %q = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestCompletionWithoutVault(t *testing.T) {
+	// No vault yet: nothing is offered and nothing fails
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("APPDATA", home)
+	if got := complete(t, home, "get", ""); len(got) != 0 {
+		t.Errorf("completion without a vault = %q", got)
+	}
+
+	// An unreadable vault is treated the same
+	if err := os.MkdirAll(config.GetDefaultDataDir(), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config.GetDefaultKeysPath(), []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got := complete(t, home, "set", "myapp", ""); len(got) != 0 {
+		t.Errorf("completion with a corrupted vault = %q", got)
+	}
+}
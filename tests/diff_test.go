@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"testing"
+
+	"envy/internal/domain"
+	"envy/internal/service"
+)
+
+func TestDiffProjects(t *testing.T) {
+	left := createTestProject("app", "dev", "SHARED", "ONLY_DEV", "CHANGED")
+	right := createTestProject("app", "prod", "SHARED", "ONLY_PROD", "CHANGED")
+	right.Keys[2].Current.Value = "different"
+
+	diffs := service.DiffProjects(left, right)
+
+	want := map[string]service.DiffKind{
+		"CHANGED":   service.DiffChanged,
+		"ONLY_DEV":  service.DiffOnlyLeft,
+		"ONLY_PROD": service.DiffOnlyRight,
+		"SHARED":    service.DiffSame,
+	}
+
+	if len(diffs) != len(want) {
+		t.Fatalf("DiffProjects() returned %d entries, want %d", len(diffs), len(want))
+	}
+
+	for i, d := range diffs {
+		if i > 0 && diffs[i-1].Key > d.Key {
+			t.Errorf("DiffProjects() not sorted: %q before %q", diffs[i-1].Key, d.Key)
+		}
+		if d.Kind != want[d.Key] {
+			t.Errorf("DiffProjects() %s kind = %v, want %v", d.Key, d.Kind, want[d.Key])
+		}
+	}
+
+	if !service.HasDrift(diffs) {
+		t.Error("HasDrift() = false, want true")
+	}
+
+	if service.HasDrift(service.DiffProjects(left, left)) {
+		t.Error("HasDrift() should be false when comparing a project with itself")
+	}
+}
+
+func TestDiffProjectsEmpty(t *testing.T) {
+	diffs := service.DiffProjects(domain.Project{}, domain.Project{})
+	if len(diffs) != 0 {
+		t.Errorf("DiffProjects() on empty projects returned %d entries", len(diffs))
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+
+	if service.Fingerprint("secret", salt) != service.Fingerprint("secret", salt) {
+		t.Error("Fingerprint() should be stable for the same value and salt")
+	}
+
+	if service.Fingerprint("secret", salt) == service.Fingerprint("other", salt) {
+		t.Error("Fingerprint() should differ for different values")
+	}
+
+	if service.Fingerprint("secret", salt) == service.Fingerprint("secret", []byte("another-salt")) {
+		t.Error("Fingerprint() should differ for different salts")
+	}
+}
@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"envy/internal/domain"
+	"envy/internal/service"
+)
+
+func TestParseDotenvKeepsOrderAndComments(t *testing.T) {
+	data := "# --- Database ---\n" +
+		"\n" +
+		"# Primary connection\n" +
+		"# read-write\n" +
+		"DB_URL=postgres://db\n" +
+		"ZED=1\n" +
+		"\n" +
+		"# API_KEY=old\n" +
+		"\n" +
+		"ALPHA=\"multi\\nline\"\n"
+
+	doc, err := service.ParseDotenv([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseDotenv: %v", err)
+	}
+
+	want := []service.ImportedKey{
+		{Key: "DB_URL", Value: "postgres://db", Description: "Primary connection\nread-write"},
+		{Key: "ZED", Value: "1"},
+		{Key: "ALPHA", Value: "multi\nline"},
+	}
+	if !reflect.DeepEqual(doc.Keys, want) {
+		t.Errorf("keys = %+v, want %+v", doc.Keys, want)
+	}
+
+	wantLayout := []domain.LayoutLine{
+		{Text: "# --- Database ---"},
+		{},
+		{Key: "DB_URL"},
+		{Key: "ZED"},
+		{},
+		{Text: "# API_KEY=old"},
+		{},
+		{Key: "ALPHA"},
+	}
+	if !reflect.DeepEqual(doc.Layout, wantLayout) {
+		t.Errorf("layout = %+v, want %+v", doc.Layout, wantLayout)
+	}
+}
+
+func importDotenv(t *testing.T, project *domain.Project, data []byte, strategy string) {
+	t.Helper()
+	doc, err := service.ParseDotenv(data)
+	if err != nil {
+		t.Fatalf("ParseDotenv: %v", err)
+	}
+	summary, err := service.ImportKeys(project, doc.Keys, strategy, "test", time.Now())
+	if err != nil {
+		t.Fatalf("ImportKeys: %v", err)
+	}
+	service.ApplyDotenvLayout(project, doc.Keys, doc.Layout, strategy, summary)
+}
+
+func TestDotenvRoundTrip(t *testing.T) {
+	source := "# Shared settings\n" +
+		"\n" +
+		"# Where the app listens\n" +
+		"PORT=8080\n" +
+		"HOST=0.0.0.0\n" +
+		"\n" +
+		"# --- Secrets ---\n" +
+		"#\n" +
+		"# Rotated monthly\n" +
+		"#\n" +
+		"#   see the runbook\n" +
+		"API_KEY='sk live'\n" +
+		"MOTD=\"line one\\nline two\"\n" +
+		"# trailing note\n"
+
+	project := domain.Project{Name: "myapp", Environment: domain.EnvDev}
+	importDotenv(t, &project, []byte(source), service.ImportMerge)
+
+	exported, err := service.FormatProject("dotenv", project)
+	if err != nil {
+		t.Fatalf("FormatProject: %v", err)
+	}
+	want := "# Exported from Envy - Project: myapp (dev)\n" + source
+	if string(exported) != want {
+		t.Fatalf("export =\n%s\nwant\n%s", exported, want)
+	}
+
+	// Importing the unchanged export into a fresh project gives the same file
+	again := domain.Project{Name: "myapp", Environment: domain.EnvDev}
+	importDotenv(t, &again, exported, service.ImportReplace)
+	reexported, _ := service.FormatProject("dotenv", again)
+	if string(reexported) != string(exported) {
+		t.Errorf("re-export =\n%s\nwant\n%s", reexported, exported)
+	}
+}
+
+func TestApplyDotenvLayoutOrdersKeys(t *testing.T) {
+	project := domain.Project{Name: "myapp", Environment: domain.EnvDev}
+	for _, key := range []string{"C", "EXTRA", "A"} {
+		project.Keys = append(project.Keys, domain.APIKey{Key: key, Title: key, Description: "old"})
+	}
+
+	importDotenv(t, &project, []byte("# first\nA=1\nB=2\nC=3\n"), service.ImportMerge)
+
+	var order []string
+	for _, k := range project.Keys {
+		order = append(order, k.Key)
+	}
+	if want := []string{"A", "B", "C", "EXTRA"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+	if project.Keys[0].Description != "first" || project.Keys[2].Description != "" || project.Keys[3].Description != "old" {
+		t.Errorf("descriptions = %+v", project.Keys)
+	}
+
+	// skip-existing leaves existing keys and the layout alone
+	importDotenv(t, &project, []byte("# new\nC=9\nD=4\n"), service.ImportSkipExisting)
+	if project.Keys[2].Description != "" || project.Keys[len(project.Keys)-1].Key != "D" {
+		t.Errorf("skip-existing changed existing keys: %+v", project.Keys)
+	}
+	if len(project.Layout) != 3 {
+		t.Errorf("layout = %+v, want the first import's", project.Layout)
+	}
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"envy/internal/service"
+
+	"github.com/hashicorp/go-envparse"
+)
+
+func TestMergeDotenvKeepsLocalSettings(t *testing.T) {
+	existing := "# local overrides\n" +
+		"DEBUG=true\n" +
+		"\n" +
+		"export API_KEY=old\n" +
+		"CERT=\"-----BEGIN\n" +
+		"old\n" +
+		"-----END\"\n" +
+		"PORT=3000 # local port\n"
+
+	project := projectWithValues("myapp", "dev", map[string]string{
+		"API_KEY": "new key",
+		"CERT":    "fresh",
+		"EXTRA":   "x",
+	})
+
+	merged := service.MergeDotenv([]byte(existing), project)
+
+	want := "# local overrides\n" +
+		"DEBUG=true\n" +
+		"\n" +
+		"export API_KEY='new key'\n" +
+		"CERT=fresh\n" +
+		"PORT=3000 # local port\n" +
+		"EXTRA=x\n"
+	if string(merged.Content) != want {
+		t.Errorf("merged file:\n%s\nwant:\n%s", merged.Content, want)
+	}
+
+	if !reflect.DeepEqual(merged.Updated, []string{"API_KEY", "CERT"}) {
+		t.Errorf("Updated = %v", merged.Updated)
+	}
+	if !reflect.DeepEqual(merged.Added, []string{"EXTRA"}) {
+		t.Errorf("Added = %v", merged.Added)
+	}
+	if !reflect.DeepEqual(merged.Kept, []string{"DEBUG", "PORT"}) {
+		t.Errorf("Kept = %v", merged.Kept)
+	}
+}
+
+func TestMergeDotenvRoundTrip(t *testing.T) {
+	project := trickyProject()
+	existing := "LOCAL=1\nKEY_A=stale\n"
+
+	merged := service.MergeDotenv([]byte(existing), project)
+
+	got, err := envparse.Parse(bytes.NewReader(merged.Content))
+	if err != nil {
+		t.Fatalf("merged file doesn't parse: %v\n%s", err, merged.Content)
+	}
+	if got["LOCAL"] != "1" {
+		t.Errorf("LOCAL = %q, want 1", got["LOCAL"])
+	}
+	for _, k := range project.Keys {
+		if got[k.Key] != k.Current.Value {
+			t.Errorf("%s = %q, want %q", k.Key, got[k.Key], k.Current.Value)
+		}
+	}
+}
+
+func TestMergeDotenvNoTrailingNewline(t *testing.T) {
+	project := projectWithValues("myapp", "dev", map[string]string{"B": "2"})
+
+	merged := service.MergeDotenv([]byte("A=1"), project)
+
+	if want := "A=1\nB=2\n"; string(merged.Content) != want {
+		t.Errorf("merged file = %q, want %q", merged.Content, want)
+	}
+}
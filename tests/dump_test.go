@@ -0,0 +1,206 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"envy/internal/domain"
+	"envy/internal/service"
+)
+
+func dumpProjects() []domain.Project {
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	return []domain.Project{
+		{
+			Name:        "myapp",
+			Environment: "prod",
+			Keys: []domain.APIKey{
+				{
+					Key:         "API_KEY",
+					Description: "Stripe",
+					Current:     domain.SecretVersion{Value: "sk_2", CreatedAt: created.Add(time.Hour), CreatedBy: "alice"},
+					History:     []domain.SecretVersion{{Value: "sk_1", CreatedAt: created, CreatedBy: "bob"}},
+				},
+				{Key: "DB_URL", Current: domain.SecretVersion{Value: "postgres://db", CreatedAt: created}},
+			},
+			Layout: []domain.LayoutLine{{Text: "# Payments"}, {Key: "API_KEY"}, {Key: "DB_URL"}},
+		},
+		{Name: "shared", Environment: "dev", Keys: []domain.APIKey{
+			{Key: "TOKEN", Current: domain.SecretVersion{Value: "t", CreatedAt: created}},
+		}},
+	}
+}
+
+func TestNewDumpHistory(t *testing.T) {
+	projects := dumpProjects()
+
+	full := service.NewDump(projects, true, time.Now())
+	if p, k, v := full.Count(); p != 2 || k != 3 || v != 4 {
+		t.Errorf("Count() = %d, %d, %d, want 2, 3, 4", p, k, v)
+	}
+
+	current := service.NewDump(projects, false, time.Now())
+	if len(current.Projects[0].Keys[0].History) != 0 {
+		t.Error("history included without includeHistory")
+	}
+	if len(projects[0].Keys[0].History) != 1 {
+		t.Error("NewDump changed the vault's projects")
+	}
+	if _, _, v := current.Count(); v != 3 {
+		t.Errorf("versions = %d, want 3", v)
+	}
+}
+
+func TestDumpRoundTrip(t *testing.T) {
+	dump := service.NewDump(dumpProjects(), true, time.Now())
+	noPrompt := func() (string, error) { return "", errors.New("prompted for a plain dump") }
+
+	plain, err := service.MarshalDump(dump, "")
+	if err != nil {
+		t.Fatalf("MarshalDump: %v", err)
+	}
+	parsed, err := service.ParseDump(plain, noPrompt)
+	if err != nil {
+		t.Fatalf("ParseDump: %v", err)
+	}
+	if c := service.CompareVaults(dump, parsed.Projects); !c.Equivalent() {
+		t.Errorf("plain dump doesn't round trip: %+v", c)
+	}
+
+	encrypted, err := service.MarshalDump(dump, "correct horse")
+	if err != nil {
+		t.Fatalf("MarshalDump encrypted: %v", err)
+	}
+	if strings.Contains(string(encrypted), "sk_2") {
+		t.Error("encrypted dump contains a value in plain text")
+	}
+	parsed, err = service.ParseDump(encrypted, func() (string, error) { return "correct horse", nil })
+	if err != nil {
+		t.Fatalf("ParseDump encrypted: %v", err)
+	}
+	if c := service.CompareVaults(dump, parsed.Projects); !c.Equivalent() {
+		t.Errorf("encrypted dump doesn't round trip: %+v", c)
+	}
+
+	if _, err := service.ParseDump(encrypted, func() (string, error) { return "wrong horse", nil }); err == nil {
+		t.Error("wrong passphrase accepted")
+	}
+}
+
+func TestParseDumpRejects(t *testing.T) {
+	noPrompt := func() (string, error) { return "", nil }
+	tests := map[string]string{
+		"not json":      "KEY=value",
+		"other format":  `{"format":"something","version":1}`,
+		"newer version": `{"format":"envy-dump","version":99}`,
+	}
+	for name, data := range tests {
+		if _, err := service.ParseDump([]byte(data), noPrompt); err == nil {
+			t.Errorf("%s: ParseDump succeeded", name)
+		}
+	}
+}
+
+func TestCompareVaults(t *testing.T) {
+	dump := service.NewDump(dumpProjects(), true, time.Now())
+
+	vault := dumpProjects()
+	if c := service.CompareVaults(dump, vault); !c.Equivalent() {
+		t.Fatalf("identical vault differs: %+v", c)
+	}
+
+	vault[0].Keys[0].History[0].Value = "changed"
+	vault[0].Keys[0], vault[0].Keys[1] = vault[0].Keys[1], vault[0].Keys[0]
+	vault[0].Keys = append(vault[0].Keys, domain.APIKey{Key: "NEW"})
+	vault[1].Environment = "prod"
+
+	c := service.CompareVaults(dump, vault)
+	if c.Equivalent() {
+		t.Fatal("changed vault reported equivalent")
+	}
+	want := map[string][]string{
+		"missing":   {"shared (dev)"},
+		"extra":     {"myapp (prod) NEW", "shared (prod)"},
+		"different": {"myapp (prod) API_KEY: history", "myapp (prod): key order"},
+	}
+	got := map[string][]string{"missing": c.Missing, "extra": c.Extra, "different": c.Different}
+	for name, entries := range want {
+		if strings.Join(got[name], "|") != strings.Join(entries, "|") {
+			t.Errorf("%s = %q, want %q", name, got[name], entries)
+		}
+	}
+
+	// Without history, only current values are compared
+	current := service.NewDump(dumpProjects(), false, time.Now())
+	vault = dumpProjects()
+	vault[0].Keys[0].History = nil
+	if c := service.CompareVaults(current, vault); !c.Equivalent() {
+		t.Errorf("history compared for a dump without it: %+v", c)
+	}
+}
+
+func TestCompareVaultsCountsDuplicateKeys(t *testing.T) {
+	dump := service.NewDump(dumpProjects(), false, time.Now())
+
+	// A second API_KEY in the vault must not hide behind the first
+	vault := dumpProjects()
+	vault[0].Keys = append(vault[0].Keys, vault[0].Keys[0])
+	c := service.CompareVaults(dump, vault)
+	if c.Equivalent() {
+		t.Fatal("vault with a duplicate key reported equivalent")
+	}
+	if strings.Join(c.Extra, "|") != "myapp (prod) API_KEY" {
+		t.Errorf("extra = %q, want the second API_KEY", c.Extra)
+	}
+
+	// And a duplicate in the dump isn't matched twice by one vault key
+	projects := dumpProjects()
+	projects[0].Keys = append(projects[0].Keys, projects[0].Keys[1])
+	c = service.CompareVaults(service.NewDump(projects, false, time.Now()), dumpProjects())
+	if strings.Join(c.Missing, "|") != "myapp (prod) DB_URL" {
+		t.Errorf("missing = %q, want the second DB_URL", c.Missing)
+	}
+}
+
+func TestValidateDump(t *testing.T) {
+	if err := service.ValidateDump(service.NewDump(dumpProjects(), true, time.Now())); err != nil {
+		t.Fatalf("ValidateDump() error: %v", err)
+	}
+
+	tests := map[string]struct {
+		edit    func(projects []domain.Project) []domain.Project
+		wantErr string
+	}{
+		"duplicate key": {func(p []domain.Project) []domain.Project {
+			p[0].Keys = append(p[0].Keys, domain.APIKey{Key: "API_KEY", Current: domain.SecretVersion{Value: "other"}})
+			return p
+		}, "key 'API_KEY' appears twice"},
+		"duplicate project": {func(p []domain.Project) []domain.Project {
+			return append(p, domain.Project{Name: "MyApp", Environment: "prod"})
+		}, "appears twice"},
+		"invalid environment": {func(p []domain.Project) []domain.Project {
+			p[1].Environment = "qa"
+			return p
+		}, "project 'shared'"},
+		"invalid key": {func(p []domain.Project) []domain.Project {
+			p[1].Keys[0].Key = "A=B"
+			return p
+		}, "project 'shared' (dev)"},
+	}
+	for name, tt := range tests {
+		dump := service.NewDump(tt.edit(dumpProjects()), true, time.Now())
+		err := service.ValidateDump(dump)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: ValidateDump() error = %v, want %q", name, err, tt.wantErr)
+		}
+	}
+
+	// Keys of different projects may share names
+	projects := dumpProjects()
+	projects[1].Keys[0].Key = "API_KEY"
+	if err := service.ValidateDump(service.NewDump(projects, true, time.Now())); err != nil {
+		t.Errorf("same key in two projects: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"envy/internal/service"
+)
+
+func TestBuildEnvironment(t *testing.T) {
+	environ := []string{"PATH=/usr/bin", "API_KEY=old", "DB_URL=same", "HOME=/home/me"}
+	injected := []service.LayeredValue{
+		{Key: "API_KEY", Value: "new"},
+		{Key: "DB_URL", Value: "same"},
+		{Key: "TOKEN", Value: "abc"},
+	}
+
+	env, collisions := service.BuildEnvironment(environ, injected)
+
+	want := []string{"PATH=/usr/bin", "HOME=/home/me", "API_KEY=new", "DB_URL=same", "TOKEN=abc"}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("BuildEnvironment() env = %v, want %v", env, want)
+	}
+	if len(collisions) != 1 || collisions[0].Key != "API_KEY" || collisions[0].Inherited != "old" {
+		t.Errorf("BuildEnvironment() collisions = %+v, want only API_KEY", collisions)
+	}
+}
+
+func TestKeepEnvironment(t *testing.T) {
+	environ := []string{"PATH=/usr/bin", "HOME=/home/me", "LC_ALL=C", "LC_CTYPE=C", "DATABASE_URL=postgres://"}
+
+	kept, err := service.KeepEnvironment(environ, []string{"PATH", "LC_*"})
+	if err != nil {
+		t.Fatalf("KeepEnvironment() error: %v", err)
+	}
+	want := []string{"PATH=/usr/bin", "LC_ALL=C", "LC_CTYPE=C"}
+	if !reflect.DeepEqual(kept, want) {
+		t.Errorf("KeepEnvironment() = %v, want %v", kept, want)
+	}
+
+	kept, err = service.KeepEnvironment(environ, nil)
+	if err != nil || len(kept) != 0 {
+		t.Errorf("KeepEnvironment(nil) = %v, %v, want empty", kept, err)
+	}
+
+	if _, err := service.KeepEnvironment(environ, []string{"["}); err == nil {
+		t.Error("KeepEnvironment() with invalid pattern should fail")
+	}
+}
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"envy/internal/service"
+)
+
+func TestFindEnvFiles(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "services")
+	for _, name := range []string{
+		".env.prod",
+		"api/.env.development",
+		"api/.env.production",
+		"api/.env.example",
+		"api/.env",
+		"workers/billing/.env.qa",
+		"web/node_modules/pkg/.env.dev",
+		".git/.env.dev",
+		"web/README.md",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0o700)
+		os.WriteFile(path, []byte("KEY=value\n"), 0o600)
+	}
+
+	suffixes := map[string]string{"qa": "stage"}
+	for suffix, env := range service.DefaultEnvironmentSuffixes {
+		suffixes[suffix] = env
+	}
+
+	files, skipped, err := service.FindEnvFiles(root, suffixes)
+	if err != nil {
+		t.Fatalf("FindEnvFiles: %v", err)
+	}
+
+	var got []string
+	for _, f := range files {
+		rel, _ := filepath.Rel(root, f.Path)
+		got = append(got, filepath.ToSlash(rel)+" "+f.Project+":"+f.Environment)
+	}
+	want := []string{
+		".env.prod services:prod",
+		"api/.env.development api:dev",
+		"api/.env.production api:prod",
+		"workers/billing/.env.qa workers/billing:stage",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+
+	var skippedNames []string
+	for _, s := range skipped {
+		rel, _ := filepath.Rel(root, s.Path)
+		skippedNames = append(skippedNames, filepath.ToSlash(rel))
+	}
+	if !reflect.DeepEqual(skippedNames, []string{"api/.env", "api/.env.example"}) {
+		t.Errorf("skipped = %v", skipped)
+	}
+}
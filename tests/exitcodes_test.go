@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"envy/internal/commands"
+	"envy/internal/domain"
+	"envy/internal/service"
+	"envy/internal/storage"
+)
+
+func TestErrorExitCodes(t *testing.T) {
+	_, ambiguous := service.ResolveProject([]domain.Project{
+		{Name: "myapp", Environment: "dev"},
+		{Name: "myapp", Environment: "prod"},
+	}, "myapp", "", "")
+	invalidEnv := domain.ValidateEnvironment("production")
+	if ambiguous == nil || invalidEnv == nil {
+		t.Fatal("expected an ambiguous project and an invalid environment")
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"plain error", errors.New("boom"), commands.ExitFailure},
+		{"usage", &commands.CommandError{Code: commands.ExitUsage, Err: errors.New("bad flag")}, commands.ExitUsage},
+		{"validation", fmt.Errorf("invalid environment: %w", invalidEnv), commands.ExitUsage},
+		{"ambiguous project", ambiguous, commands.ExitUsage},
+		{"wrong password", fmt.Errorf("failed to load vault: %w", storage.ErrIncorrectPassword), commands.ExitAuth},
+		{"not found", fmt.Errorf("project 'x' %w", domain.ErrNotFound), commands.ExitNotFound},
+		{"vault", &commands.CommandError{Code: commands.ExitVault, Err: errors.New("no vault")}, commands.ExitVault},
+		{"cancelled", fmt.Errorf("export %w", commands.ErrCancelled), commands.ExitCancelled},
+		{"drift", &commands.CommandError{Code: commands.ExitDrift, Err: errors.New("differs")}, commands.ExitDrift},
+		{"code wins over cause", &commands.CommandError{Code: commands.ExitVault, Err: domain.ErrNotFound}, commands.ExitVault},
+		{"no code classifies cause", &commands.CommandError{Err: commands.ErrCancelled, Project: "p"}, commands.ExitCancelled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, ok := commands.NewErrorReport(tt.err)
+			if !ok {
+				t.Fatal("error reported as quiet")
+			}
+			if report.Code != tt.want {
+				t.Errorf("code = %d, want %d", report.Code, tt.want)
+			}
+			if report.Message != tt.err.Error() {
+				t.Errorf("message = %q, want %q", report.Message, tt.err.Error())
+			}
+		})
+	}
+}
+
+func TestErrorReportJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "no context",
+			err:  errors.New("boom"),
+			want: `{"code":1,"message":"boom"}`,
+		},
+		{
+			name: "key in a project",
+			err: &commands.CommandError{
+				Code:        commands.ExitNotFound,
+				Err:         fmt.Errorf("key 'API_KEY' %w", domain.ErrNotFound),
+				Project:     "myapp",
+				Environment: "prod",
+				Key:         "API_KEY",
+			},
+			want: `{"code":4,"message":"key 'API_KEY' not found","project":"myapp","environment":"prod","key":"API_KEY"}`,
+		},
+		{
+			name: "outer context wins, inner fills gaps",
+			err: &commands.CommandError{
+				Code:        commands.ExitUsage,
+				Project:     "myapp",
+				Environment: "dev",
+				Err:         &commands.CommandError{Code: commands.ExitNotFound, Err: errors.New("bad"), Project: "other", Key: "TOKEN"},
+			},
+			want: `{"code":2,"message":"bad","project":"myapp","environment":"dev","key":"TOKEN"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, _ := commands.NewErrorReport(tt.err)
+			data, err := json.Marshal(report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("report = %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+
+	"envy/internal/domain"
+	"envy/internal/service"
+
+	"github.com/hashicorp/go-envparse"
+)
+
+// trickyValues are hard to escape in at least one format
+var trickyValues = []string{
+	"plain",
+	"",
+	"with space",
+	`single'quote`,
+	`double"quote`,
+	`back\slash`,
+	"dollar $HOME ${USER} `cmd`",
+	"line1\nline2",
+	"#not-a-comment",
+	"ünïcødé ✓",
+}
+
+func trickyProject() domain.Project {
+	project := domain.Project{Name: "myapp", Environment: "dev"}
+	for i, value := range trickyValues {
+		project.Keys = append(project.Keys, domain.APIKey{
+			Key:     "KEY_" + string(rune('A'+i)),
+			Current: domain.SecretVersion{Value: value},
+		})
+	}
+	return project
+}
+
+func TestFormatProjectDotenvRoundTrip(t *testing.T) {
+	project := trickyProject()
+	out, err := service.FormatProject("dotenv", project)
+	if err != nil {
+		t.Fatalf("FormatProject() error: %v", err)
+	}
+
+	parsed, err := envparse.Parse(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("envparse.Parse() error: %v\n%s", err, out)
+	}
+	for _, k := range project.Keys {
+		if parsed[k.Key] != k.Current.Value {
+			t.Errorf("%s = %q after round trip, want %q", k.Key, parsed[k.Key], k.Current.Value)
+		}
+	}
+}
+
+func TestFormatProjectJSONRoundTrip(t *testing.T) {
+	project := trickyProject()
+	out, err := service.FormatProject("json", project)
+	if err != nil {
+		t.Fatalf("FormatProject() error: %v", err)
+	}
+
+	var parsed map[string]string
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		t.Fatalf("json.Unmarshal() error: %v\n%s", err, out)
+	}
+	for _, k := range project.Keys {
+		if parsed[k.Key] != k.Current.Value {
+			t.Errorf("%s = %q after round trip, want %q", k.Key, parsed[k.Key], k.Current.Value)
+		}
+	}
+	if strings.Index(string(out), "KEY_A") > strings.Index(string(out), "KEY_B") {
+		t.Error("json export doesn't keep the key order")
+	}
+}
+
+func TestFormatProjectShellRoundTrip(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	project := trickyProject()
+	out, err := service.FormatProject("shell", project)
+	if err != nil {
+		t.Fatalf("FormatProject() error: %v", err)
+	}
+
+	for _, k := range project.Keys {
+		script := string(out) + `printf '%s' "$` + k.Key + `"`
+		got, err := exec.Command("sh", "-c", script).Output()
+		if err != nil {
+			t.Fatalf("sh error: %v\n%s", err, out)
+		}
+		if string(got) != k.Current.Value {
+			t.Errorf("%s = %q after round trip, want %q", k.Key, got, k.Current.Value)
+		}
+	}
+}
+
+func TestFormatProjectEscaping(t *testing.T) {
+	project := domain.Project{Name: "myapp", Environment: "dev", Keys: []domain.APIKey{
+		{Key: "K", Current: domain.SecretVersion{Value: `it's "x" \ $y ü`}},
+	}}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"shell", `export K='it'\''s "x" \ $y ü'`},
+		{"fish", `set -gx K 'it\'s "x" \\ $y ü'`},
+		{"docker", `K=it's "x" \ $y ü`},
+		{"systemd", `K="it's \"x\" \\ \$y ü"`},
+		{"properties", `K=it's "x" \\ $y \u00FC`},
+		{"toml", `K = "it's \"x\" \\ $y ü"`},
+		{"yaml", `K: "it's \"x\" \\ $y ü"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			out, err := service.FormatProject(tt.format, project)
+			if err != nil {
+				t.Fatalf("FormatProject() error: %v", err)
+			}
+			lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+			if got := lines[len(lines)-1]; got != tt.want {
+				t.Errorf("FormatProject(%s) = %s, want %s", tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatProjectYAMLKeys(t *testing.T) {
+	// YAML 1.1 loaders read the reserved words as booleans or null when bare
+	quoted := []string{"ON", "OFF", "YES", "NO", "Y", "N", "TRUE", "FALSE", "NULL", "on", "Off", "yes", "My-Key"}
+	bare := []string{"ONLINE", "NODE_ENV", "Y2K", "API_KEY"}
+
+	project := domain.Project{Name: "myapp", Environment: "dev"}
+	for _, key := range append(append([]string{}, quoted...), bare...) {
+		project.Keys = append(project.Keys, domain.APIKey{Key: key, Current: domain.SecretVersion{Value: "v"}})
+	}
+	out, err := service.FormatProject("yaml", project)
+	if err != nil {
+		t.Fatalf("FormatProject() error: %v", err)
+	}
+
+	for _, key := range quoted {
+		if !strings.Contains(string(out), "\n\""+key+"\": \"v\"\n") {
+			t.Errorf("key %s isn't quoted:\n%s", key, out)
+		}
+	}
+	for _, key := range bare {
+		if !strings.Contains(string(out), "\n"+key+": \"v\"\n") {
+			t.Errorf("key %s isn't bare:\n%s", key, out)
+		}
+	}
+}
+
+func TestFormatProjectErrors(t *testing.T) {
+	multiline := domain.Project{Keys: []domain.APIKey{{Key: "K", Current: domain.SecretVersion{Value: "a\nb"}}}}
+	if _, err := service.FormatProject("docker", multiline); err == nil {
+		t.Error("docker format should reject multi-line values")
+	}
+
+	badName := domain.Project{Keys: []domain.APIKey{{Key: "my-key", Current: domain.SecretVersion{Value: "v"}}}}
+	for _, format := range []string{"shell", "fish", "systemd"} {
+		if _, err := service.FormatProject(format, badName); err == nil {
+			t.Errorf("%s format should reject key 'my-key'", format)
+		}
+	}
+
+	if _, err := service.FormatProject("xml", badName); err == nil {
+		t.Error("unknown format should fail")
+	}
+}
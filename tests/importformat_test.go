@@ -0,0 +1,234 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"envy/internal/service"
+)
+
+func TestDetectImportFormat(t *testing.T) {
+	tests := []struct {
+		path string
+		data string
+		want string
+	}{
+		{".env", "A=1\n", "dotenv"},
+		{".env.production", "A=1\n", "dotenv"},
+		{"secrets.json", `{"A": "1"}`, "json"},
+		{"-", "  {\"A\": \"1\"}", "json"},
+		{"config.yaml", "a: 1\n", "yaml"},
+		{"docker-compose.yml", "version: '3'\n", "compose"},
+		{"stack.yml", "services:\n  web:\n    image: nginx\n", "compose"},
+		{"secret.yaml", "apiVersion: v1\nkind: Secret\n", "k8s"},
+		{"all.yaml", "kind: ConfigMap\n---\nkind: Secret\n", "k8s"},
+	}
+
+	for _, tt := range tests {
+		if got := service.DetectImportFormat(tt.path, []byte(tt.data)); got != tt.want {
+			t.Errorf("DetectImportFormat(%q) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseImportFlattensNestedObjects(t *testing.T) {
+	data := `{
+  "API_KEY": "abc",
+  "db": {"host": "localhost", "port": 5432, "ssl": true, "replicas": ["r1", "r2"]},
+  "empty": null
+}`
+
+	docs, err := service.ParseImport("json", []byte(data), "__")
+	if err != nil {
+		t.Fatalf("ParseImport: %v", err)
+	}
+
+	want := []service.ImportedKey{
+		{Key: "API_KEY", Value: "abc"},
+		{Key: "db__host", Value: "localhost"},
+		{Key: "db__port", Value: "5432"},
+		{Key: "db__ssl", Value: "true"},
+		{Key: "db__replicas__0", Value: "r1"},
+		{Key: "db__replicas__1", Value: "r2"},
+		{Key: "empty", Value: ""},
+	}
+	if len(docs) != 1 || !reflect.DeepEqual(docs[0].Keys, want) {
+		t.Errorf("keys = %+v, want %+v", docs, want)
+	}
+}
+
+func TestParseImportMergesYAMLDocuments(t *testing.T) {
+	data := "A: 1\nB: first\n---\nB: second\nmulti: |\n  line1\n  line2\n"
+
+	docs, err := service.ParseImport("yaml", []byte(data), "_")
+	if err != nil {
+		t.Fatalf("ParseImport: %v", err)
+	}
+
+	want := []service.ImportedKey{{Key: "A", Value: "1"}, {Key: "B", Value: "second"}, {Key: "multi", Value: "line1\nline2\n"}}
+	if len(docs) != 1 || !reflect.DeepEqual(docs[0].Keys, want) {
+		t.Errorf("keys = %+v, want %+v", docs, want)
+	}
+}
+
+func TestParseImportCompose(t *testing.T) {
+	data := `
+services:
+  api:
+    image: api
+    environment:
+      DATABASE_URL: postgres://db/app
+      DEBUG: "false"
+      FROM_SHELL:
+  worker:
+    environment:
+      - QUEUE=jobs
+      - PASSTHROUGH
+  db:
+    image: postgres
+`
+
+	docs, err := service.ParseImport("compose", []byte(data), "_")
+	if err != nil {
+		t.Fatalf("ParseImport: %v", err)
+	}
+
+	want := []service.ImportDocument{
+		{Name: "api", Keys: []service.ImportedKey{{Key: "DATABASE_URL", Value: "postgres://db/app"}, {Key: "DEBUG", Value: "false"}}},
+		{Name: "worker", Keys: []service.ImportedKey{{Key: "QUEUE", Value: "jobs"}}},
+	}
+	if !reflect.DeepEqual(docs, want) {
+		t.Errorf("docs = %+v, want %+v", docs, want)
+	}
+}
+
+func TestParseImportComposeMergeKeys(t *testing.T) {
+	data := `
+x-common-env: &common
+  LOG_LEVEL: info
+  API_KEY: default
+x-db-env: &db
+  DATABASE_URL: postgres://db/app
+  LOG_LEVEL: debug
+x-service: &service
+  environment:
+    FROM_TEMPLATE: "yes"
+services:
+  api:
+    environment:
+      <<: *common
+      API_KEY: abc
+  worker:
+    environment:
+      <<: [*db, *common]
+      QUEUE: jobs
+  cron:
+    <<: *service
+`
+
+	docs, err := service.ParseImport("compose", []byte(data), "_")
+	if err != nil {
+		t.Fatalf("ParseImport: %v", err)
+	}
+
+	want := []service.ImportDocument{
+		{Name: "api", Keys: []service.ImportedKey{{Key: "LOG_LEVEL", Value: "info"}, {Key: "API_KEY", Value: "abc"}}},
+		{Name: "worker", Keys: []service.ImportedKey{
+			{Key: "DATABASE_URL", Value: "postgres://db/app"},
+			{Key: "LOG_LEVEL", Value: "debug"},
+			{Key: "API_KEY", Value: "default"},
+			{Key: "QUEUE", Value: "jobs"},
+		}},
+		{Name: "cron", Keys: []service.ImportedKey{{Key: "FROM_TEMPLATE", Value: "yes"}}},
+	}
+	if !reflect.DeepEqual(docs, want) {
+		t.Errorf("docs = %+v, want %+v", docs, want)
+	}
+}
+
+func TestParseImportYAMLMergeKeys(t *testing.T) {
+	data := `
+base: &b
+  host: db.internal
+  port: 5432
+prod:
+  <<: *b
+  port: 1
+`
+
+	docs, err := service.ParseImport("yaml", []byte(data), "_")
+	if err != nil {
+		t.Fatalf("ParseImport: %v", err)
+	}
+
+	want := []service.ImportedKey{
+		{Key: "base_host", Value: "db.internal"},
+		{Key: "base_port", Value: "5432"},
+		{Key: "prod_host", Value: "db.internal"},
+		{Key: "prod_port", Value: "1"},
+	}
+	if len(docs) != 1 || !reflect.DeepEqual(docs[0].Keys, want) {
+		t.Errorf("keys = %+v, want %+v", docs, want)
+	}
+}
+
+func TestParseImportKubernetesSecrets(t *testing.T) {
+	data := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  IGNORED: "yes"
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: api
+type: Opaque
+data:
+  TOKEN: c2VjcmV0
+  OVERRIDDEN: b2xk
+stringData:
+  OVERRIDDEN: new
+  PLAIN: text
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+data:
+  PASSWORD: aHVudGVyMg==
+`
+
+	docs, err := service.ParseImport("k8s", []byte(data), "_")
+	if err != nil {
+		t.Fatalf("ParseImport: %v", err)
+	}
+
+	want := []service.ImportDocument{
+		{Name: "api", Keys: []service.ImportedKey{{Key: "TOKEN", Value: "secret"}, {Key: "OVERRIDDEN", Value: "new"}, {Key: "PLAIN", Value: "text"}}},
+		{Name: "db", Keys: []service.ImportedKey{{Key: "PASSWORD", Value: "hunter2"}}},
+	}
+	if !reflect.DeepEqual(docs, want) {
+		t.Errorf("docs = %+v, want %+v", docs, want)
+	}
+}
+
+func TestParseImportRejectsInvalidInput(t *testing.T) {
+	tests := []struct {
+		format string
+		data   string
+	}{
+		{"json", `["not", "an", "object"]`},
+		{"yaml", "just a string\n"},
+		{"k8s", "kind: Secret\ndata:\n  A: not*base64\n"},
+		{"k8s", "kind: ConfigMap\n"},
+		{"compose", "services:\n  web:\n    image: nginx\n"},
+	}
+
+	for _, tt := range tests {
+		if _, err := service.ParseImport(tt.format, []byte(tt.data), "_"); err == nil {
+			t.Errorf("ParseImport(%s, %q) succeeded, want an error", tt.format, tt.data)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"envy/internal/domain"
+	"envy/internal/service"
+)
+
+func importTestProject() domain.Project {
+	project := domain.Project{Name: "myapp", Environment: "dev"}
+	for _, kv := range [][2]string{{"SAME", "1"}, {"CHANGED", "old"}, {"LOCAL", "mine"}} {
+		project.Keys = append(project.Keys, domain.APIKey{
+			Key:     kv[0],
+			Current: domain.SecretVersion{Value: kv[1], CreatedBy: "cli-set"},
+		})
+	}
+	return project
+}
+
+var importTestKeys = []service.ImportedKey{
+	{Key: "SAME", Value: "1"},
+	{Key: "CHANGED", Value: "new"},
+	{Key: "NEW", Value: "added"},
+}
+
+func currentValues(project domain.Project) map[string]string {
+	values := make(map[string]string)
+	for _, k := range project.Keys {
+		values[k.Key] = k.Current.Value
+	}
+	return values
+}
+
+func TestImportKeysStrategies(t *testing.T) {
+	tests := []struct {
+		strategy string
+		want     map[string]string
+		summary  service.ImportSummary
+	}{
+		{
+			strategy: service.ImportMerge,
+			want:     map[string]string{"SAME": "1", "CHANGED": "new", "LOCAL": "mine", "NEW": "added"},
+			summary:  service.ImportSummary{Added: []string{"NEW"}, Updated: []string{"CHANGED"}, Unchanged: []string{"SAME"}, Skipped: []string{}},
+		},
+		{
+			strategy: service.ImportReplace,
+			want:     map[string]string{"SAME": "1", "CHANGED": "new", "NEW": "added"},
+			summary:  service.ImportSummary{Added: []string{"NEW"}, Updated: []string{"CHANGED"}, Unchanged: []string{"SAME"}, Skipped: []string{}, Removed: []string{"LOCAL"}},
+		},
+		{
+			strategy: service.ImportSkipExisting,
+			want:     map[string]string{"SAME": "1", "CHANGED": "old", "LOCAL": "mine", "NEW": "added"},
+			summary:  service.ImportSummary{Added: []string{"NEW"}, Updated: []string{}, Unchanged: []string{"SAME"}, Skipped: []string{"CHANGED"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			project := importTestProject()
+			summary, err := service.ImportKeys(&project, importTestKeys, tt.strategy, "cli-import", time.Now())
+			if err != nil {
+				t.Fatalf("ImportKeys: %v", err)
+			}
+			if got := currentValues(project); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("values = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(summary, tt.summary) {
+				t.Errorf("summary = %+v, want %+v", summary, tt.summary)
+			}
+		})
+	}
+}
+
+func TestImportKeysMergeKeepsHistory(t *testing.T) {
+	project := importTestProject()
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if _, err := service.ImportKeys(&project, importTestKeys, service.ImportMerge, "cli-import", now); err != nil {
+		t.Fatalf("ImportKeys: %v", err)
+	}
+
+	changed := project.Keys[1]
+	if len(changed.History) != 1 || changed.History[0].Value != "old" || changed.History[0].CreatedBy != "cli-set" {
+		t.Errorf("history = %+v, want the old value", changed.History)
+	}
+	if changed.Current.CreatedBy != "cli-import" || !changed.Current.CreatedAt.Equal(now) {
+		t.Errorf("current = %+v", changed.Current)
+	}
+	if same := project.Keys[0]; len(same.History) != 0 || same.Current.CreatedBy != "cli-set" {
+		t.Errorf("unchanged key was rewritten: %+v", same)
+	}
+}
+
+func TestImportKeysFailOnConflict(t *testing.T) {
+	project := importTestProject()
+
+	_, err := service.ImportKeys(&project, importTestKeys, service.ImportFailOnConflict, "cli-import", time.Now())
+
+	var conflict *service.ImportConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("err = %v, want ImportConflictError", err)
+	}
+	if !reflect.DeepEqual(conflict.Keys, []string{"CHANGED"}) {
+		t.Errorf("conflicts = %v", conflict.Keys)
+	}
+	if !reflect.DeepEqual(project, importTestProject()) {
+		t.Error("project changed despite the conflict")
+	}
+
+	noConflict := []service.ImportedKey{{Key: "SAME", Value: "1"}, {Key: "NEW", Value: "x"}}
+	if _, err := service.ImportKeys(&project, noConflict, service.ImportFailOnConflict, "cli-import", time.Now()); err != nil {
+		t.Errorf("import without conflicts failed: %v", err)
+	}
+	if currentValues(project)["NEW"] != "x" {
+		t.Error("NEW wasn't added")
+	}
+}
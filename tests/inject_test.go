@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"testing"
+
+	"envy/internal/config"
+	"envy/internal/service"
+)
+
+func TestApplyInjectRules(t *testing.T) {
+	project := createTestProject("myapp", "dev", "MYAPP_DB_URL", "MYAPP_API_KEY", "DEBUG_LEVEL", "PORT")
+
+	tests := []struct {
+		name    string
+		rules   config.InjectRules
+		want    []string
+		wantErr bool
+	}{
+		{"no rules", config.InjectRules{}, []string{"MYAPP_DB_URL", "MYAPP_API_KEY", "DEBUG_LEVEL", "PORT"}, false},
+		{"only", config.InjectRules{Only: []string{"MYAPP_*"}}, []string{"MYAPP_DB_URL", "MYAPP_API_KEY"}, false},
+		{"exclude", config.InjectRules{Exclude: []string{"DEBUG_*", "PORT"}}, []string{"MYAPP_DB_URL", "MYAPP_API_KEY"}, false},
+		{"strip prefix", config.InjectRules{StripPrefix: "MYAPP_", Only: []string{"MYAPP_*"}}, []string{"DB_URL", "API_KEY"}, false},
+		{"add prefix", config.InjectRules{Prefix: "APP_", Only: []string{"PORT"}}, []string{"APP_PORT"}, false},
+		{"map wins over prefix", config.InjectRules{
+			StripPrefix: "MYAPP_",
+			Map:         map[string]string{"MYAPP_DB_URL": "DATABASE_URL"},
+			Only:        []string{"MYAPP_*"},
+		}, []string{"DATABASE_URL", "API_KEY"}, false},
+		{"collision", config.InjectRules{Map: map[string]string{"PORT": "DEBUG_LEVEL"}}, nil, true},
+		{"invalid name", config.InjectRules{Map: map[string]string{"PORT": "A=B"}}, nil, true},
+		{"bad pattern", config.InjectRules{Only: []string{"["}}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := service.ApplyInjectRules(project, tt.rules)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyInjectRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(got.Keys) != len(tt.want) {
+				t.Fatalf("ApplyInjectRules() returned %d keys, want %d", len(got.Keys), len(tt.want))
+			}
+			for i, name := range tt.want {
+				if got.Keys[i].Key != name {
+					t.Errorf("key %d = %q, want %q", i, got.Keys[i].Key, name)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyInjectRulesKeepsValues(t *testing.T) {
+	project := createTestProject("myapp", "dev", "MYAPP_TOKEN")
+
+	got, err := service.ApplyInjectRules(project, config.InjectRules{StripPrefix: "MYAPP_"})
+	if err != nil {
+		t.Fatalf("ApplyInjectRules() error: %v", err)
+	}
+	if got.Keys[0].Current.Value != "secret-MYAPP_TOKEN" {
+		t.Errorf("value = %q, want secret-MYAPP_TOKEN", got.Keys[0].Current.Value)
+	}
+	if project.Keys[0].Key != "MYAPP_TOKEN" {
+		t.Errorf("original project was modified: %q", project.Keys[0].Key)
+	}
+}
@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"testing"
+
+	"envy/internal/domain"
+	"envy/internal/service"
+)
+
+func TestMergeLayers(t *testing.T) {
+	base := createTestProject("base", "prod", "LOG_LEVEL", "SENTRY_DSN", "API_URL")
+	app := createTestProject("myapp", "prod", "API_URL", "DB_URL", "SENTRY_DSN")
+	app.Keys[0].Current.Value = "https://app.example.com"
+	// SENTRY_DSN keeps the same value in both layers
+
+	merged := service.MergeLayers([]domain.Project{base, app})
+
+	wantOrder := []string{"LOG_LEVEL", "SENTRY_DSN", "API_URL", "DB_URL"}
+	if len(merged) != len(wantOrder) {
+		t.Fatalf("MergeLayers() returned %d values, want %d", len(merged), len(wantOrder))
+	}
+
+	byKey := make(map[string]service.LayeredValue)
+	for i, v := range merged {
+		if v.Key != wantOrder[i] {
+			t.Errorf("MergeLayers()[%d] = %s, want %s", i, v.Key, wantOrder[i])
+		}
+		byKey[v.Key] = v
+	}
+
+	if v := byKey["API_URL"]; v.Value != "https://app.example.com" || v.Source != 1 {
+		t.Errorf("API_URL = %q from layer %d, want later layer to win", v.Value, v.Source)
+	}
+	if v := byKey["API_URL"]; len(v.Overridden) != 1 || v.Overridden[0] != 0 {
+		t.Errorf("API_URL overridden = %v, want [0]", v.Overridden)
+	}
+
+	if v := byKey["SENTRY_DSN"]; len(v.Overridden) != 0 {
+		t.Errorf("SENTRY_DSN with equal values should not be a conflict, got %v", v.Overridden)
+	}
+
+	if v := byKey["LOG_LEVEL"]; v.Source != 0 {
+		t.Errorf("LOG_LEVEL source = %d, want 0", v.Source)
+	}
+}
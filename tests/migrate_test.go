@@ -0,0 +1,271 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"envy/internal/service"
+)
+
+func fieldMap(item service.ForeignItem) map[string]string {
+	fields := make(map[string]string)
+	for _, f := range item.Fields {
+		fields[f.Name] = f.Value
+	}
+	return fields
+}
+
+func TestReadBitwarden(t *testing.T) {
+	data := `{
+  "encrypted": false,
+  "folders": [{"id": "f1", "name": "Work/Backend"}],
+  "items": [
+    {"type": 1, "name": "Database", "folderId": "f1", "notes": "rotate monthly",
+     "login": {"username": "admin", "password": "hunter2", "totp": null, "uris": [{"uri": "https://db.example.com"}]},
+     "fields": [{"name": "port", "value": "5432", "type": 0}]},
+    {"type": 2, "name": "Loose note", "folderId": null, "notes": "just text"}
+  ]
+}`
+
+	items, err := service.ReadBitwarden([]byte(data))
+	if err != nil {
+		t.Fatalf("ReadBitwarden: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+
+	db := items[0]
+	if db.Folder != "Work/Backend" || db.Name != "Database" {
+		t.Errorf("item = %s/%s", db.Folder, db.Name)
+	}
+	want := map[string]string{"username": "admin", "password": "hunter2", "url": "https://db.example.com", "port": "5432", "notes": "rotate monthly"}
+	if got := fieldMap(db); !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+	if items[1].Folder != "" {
+		t.Errorf("loose item folder = %q, want none", items[1].Folder)
+	}
+
+	if _, err := service.ReadBitwarden([]byte(`{"encrypted": true, "data": "..."}`)); err == nil {
+		t.Error("encrypted export was accepted")
+	}
+}
+
+func TestReadKeePass(t *testing.T) {
+	data := `<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<KeePassFile>
+  <Root>
+    <Group>
+      <Name>Database</Name>
+      <Group>
+        <Name>Work</Name>
+        <Entry>
+          <String><Key>Title</Key><Value>Stripe</Value></String>
+          <String><Key>UserName</Key><Value>billing</Value></String>
+          <String><Key>Password</Key><Value ProtectInMemory="True">sk_live_1</Value></String>
+          <String><Key>Webhook Secret</Key><Value>whsec_1</Value></String>
+          <History>
+            <Entry>
+              <String><Key>Title</Key><Value>Stripe</Value></String>
+              <String><Key>Password</Key><Value>sk_old</Value></String>
+            </Entry>
+          </History>
+        </Entry>
+      </Group>
+      <Group>
+        <Name>Recycle Bin</Name>
+        <Entry><String><Key>Title</Key><Value>Deleted</Value></String></Entry>
+      </Group>
+    </Group>
+  </Root>
+</KeePassFile>`
+
+	items, err := service.ReadKeePass([]byte(data))
+	if err != nil {
+		t.Fatalf("ReadKeePass: %v", err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1: %+v", len(items), items)
+	}
+	if items[0].Folder != "Work" || items[0].Name != "Stripe" {
+		t.Errorf("item = %s/%s", items[0].Folder, items[0].Name)
+	}
+	want := map[string]string{"username": "billing", "password": "sk_live_1", "Webhook Secret": "whsec_1"}
+	if got := fieldMap(items[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+}
+
+func TestReadOnePasswordPUX(t *testing.T) {
+	exportData := `{"accounts": [{"vaults": [{"attrs": {"name": "Engineering"}, "items": [
+  {"overview": {"title": "AWS", "url": "https://aws.amazon.com"},
+   "details": {
+     "loginFields": [
+       {"value": "deploy", "name": "username", "designation": "username"},
+       {"value": "pa55", "name": "password", "designation": "password"}
+     ],
+     "sections": [{"fields": [
+       {"title": "Access Key", "value": {"string": "AKIA123"}},
+       {"title": "Secret Key", "value": {"concealed": "abc/xyz"}},
+       {"title": "one-time password", "value": {"totp": "otpauth://totp/x"}}
+     ]}]
+   }},
+  {"item": {"overview": {"title": "Wrapped"}, "details": {"password": "wrapped-pw"}}}
+]}]}]}`
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, _ := zw.Create("export.data")
+	w.Write([]byte(exportData))
+	zw.Close()
+
+	items, err := service.ReadOnePassword(archive.Bytes())
+	if err != nil {
+		t.Fatalf("ReadOnePassword: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+	want := map[string]string{
+		"username": "deploy", "password": "pa55", "url": "https://aws.amazon.com",
+		"Access Key": "AKIA123", "Secret Key": "abc/xyz", "totp": "otpauth://totp/x",
+	}
+	if got := fieldMap(items[0]); items[0].Folder != "Engineering" || !reflect.DeepEqual(got, want) {
+		t.Errorf("item = %s/%s %v, want %v", items[0].Folder, items[0].Name, got, want)
+	}
+	if got := fieldMap(items[1]); items[1].Name != "Wrapped" || got["password"] != "wrapped-pw" {
+		t.Errorf("wrapped item = %+v", items[1])
+	}
+}
+
+func TestReadOnePasswordCSV(t *testing.T) {
+	data := "Title,Url,Username,Password,OTPAuth,Favorite,Archived,Tags,Notes\n" +
+		"Sentry,https://sentry.io,me,\"p,w\",,false,false,,\"multi\nline\"\n"
+
+	items, err := service.ReadOnePassword([]byte(data))
+	if err != nil {
+		t.Fatalf("ReadOnePassword: %v", err)
+	}
+	if len(items) != 1 || items[0].Name != "Sentry" {
+		t.Fatalf("items = %+v", items)
+	}
+	want := map[string]string{"url": "https://sentry.io", "username": "me", "password": "p,w", "notes": "multi\nline"}
+	if got := fieldMap(items[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+}
+
+func TestReadPassStore(t *testing.T) {
+	root := t.TempDir()
+	entries := map[string]string{
+		"work/github.gpg":    "ghp_token\nlogin: octocat\nurl: https://github.com\nhttps://github.com/settings\n",
+		"email.gpg":          "mailpw\n",
+		".git/objects/x.gpg": "not an entry",
+		"work/readme.txt":    "ignored",
+	}
+	for name, content := range entries {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0o700)
+		os.WriteFile(path, []byte(content), 0o600)
+	}
+
+	// Entries are stored in plain text here, so "decrypting" just reads them
+	items, err := service.ReadPassStore(root, os.ReadFile)
+	if err != nil {
+		t.Fatalf("ReadPassStore: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2: %+v", len(items), items)
+	}
+
+	byName := make(map[string]service.ForeignItem)
+	for _, item := range items {
+		byName[item.Name] = item
+	}
+	github := byName["github"]
+	if github.Folder != "work" {
+		t.Errorf("folder = %q, want work", github.Folder)
+	}
+	want := map[string]string{"password": "ghp_token", "username": "octocat", "url": "https://github.com", "notes": "https://github.com/settings"}
+	if got := fieldMap(github); !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+	if byName["email"].Folder != "" {
+		t.Errorf("top-level entry folder = %q", byName["email"].Folder)
+	}
+}
+
+func TestPlanMigration(t *testing.T) {
+	items := []service.ForeignItem{
+		{Folder: "Work/Backend", Name: "Database", Fields: []service.ForeignField{
+			{Name: "username", Value: "admin"},
+			{Name: "password", Value: "hunter2"},
+			{Name: "url", Value: "https://db"},
+			{Name: "Read Replica", Value: "replica"},
+		}},
+		{Folder: "Work/Backend", Name: "GitHub", Fields: []service.ForeignField{
+			{Name: "username", Value: "octocat"},
+			{Name: "password", Value: "ghp_1"},
+		}},
+		{Folder: "Personal", Name: "Bank", Fields: []service.ForeignField{{Name: "password", Value: "x"}}},
+		{Folder: "", Name: "Loose", Fields: []service.ForeignField{{Name: "password", Value: "y"}}},
+		{Folder: "Work/Backend", Name: "database", Fields: []service.ForeignField{{Name: "password", Value: "again"}}},
+	}
+
+	mapping, err := service.ParseMigrationMapping([]byte(`
+projects:
+  Work/Backend: backend
+  Personal: ""
+keys:
+  username: "{item}_USER"
+items:
+  GitHub:
+    password: GITHUB_TOKEN
+    "*": ""
+`))
+	if err != nil {
+		t.Fatalf("ParseMigrationMapping: %v", err)
+	}
+
+	plan := service.PlanMigration(items, mapping)
+
+	if len(plan.Projects) != 1 || plan.Projects[0].Name != "backend" {
+		t.Fatalf("projects = %+v", plan.Projects)
+	}
+	var keys []string
+	values := make(map[string]string)
+	for _, k := range plan.Projects[0].Keys {
+		keys = append(keys, k.Key)
+		values[k.Key] = k.Value
+	}
+	wantKeys := []string{"DATABASE_USER", "DATABASE", "DATABASE_READ_REPLICA", "GITHUB_TOKEN"}
+	if !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("keys = %v, want %v", keys, wantKeys)
+	}
+	if values["DATABASE"] != "again" {
+		t.Errorf("DATABASE = %q, want the later item's value", values["DATABASE"])
+	}
+	if len(plan.Warnings) != 1 || !strings.Contains(plan.Warnings[0], "DATABASE") {
+		t.Errorf("warnings = %v", plan.Warnings)
+	}
+	if !reflect.DeepEqual(plan.Skipped, []string{"Personal", "Loose (no folder)"}) {
+		t.Errorf("skipped = %v", plan.Skipped)
+	}
+
+	mapping.DefaultProject = "misc"
+	if plan := service.PlanMigration(items, mapping); len(plan.Projects) != 2 || plan.Projects[1].Name != "misc" {
+		t.Errorf("default project not used: %+v", plan.Projects)
+	}
+}
+
+func TestParseMigrationMappingRejectsUnknownFields(t *testing.T) {
+	if _, err := service.ParseMigrationMapping([]byte("project:\n  a: b\n")); err == nil {
+		t.Error("mapping with a misspelled section was accepted")
+	}
+}
@@ -101,3 +101,20 @@ func TestEnvironmentConstants(t *testing.T) {
 		t.Errorf("EnvDev = %q, want %q", domain.EnvDev, "dev")
 	}
 }
+
+func TestMaskValue(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"short", "•••••"},
+		{"sk_live_1234567890", "sk••••••••90"},
+	}
+
+	for _, tt := range tests {
+		if got := domain.MaskValue(tt.input); got != tt.want {
+			t.Errorf("MaskValue(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+
+	"envy/internal/service"
+)
+
+func redactAll(t *testing.T, secrets map[string]string, chunks ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	r := service.NewRedactor(&out, secrets)
+	for _, chunk := range chunks {
+		if _, err := r.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+	}
+	if err := r.Flush(); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	return out.String()
+}
+
+func TestRedactor(t *testing.T) {
+	secrets := map[string]string{
+		"API_KEY":  "sk_live_abc123/xyz+",
+		"PASSWORD": "hunter22",
+		"PORT":     "80",
+	}
+
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{"raw", []string{"token=sk_live_abc123/xyz+\n"}, "token=***API_KEY***\n"},
+		{"several", []string{"hunter22 and hunter22"}, "***PASSWORD*** and ***PASSWORD***"},
+		{"base64", []string{"auth " + base64.StdEncoding.EncodeToString([]byte("sk_live_abc123/xyz+"))}, "auth ***API_KEY***"},
+		{"base64 url", []string{base64.URLEncoding.EncodeToString([]byte("sk_live_abc123/xyz+"))}, "***API_KEY***"},
+		{"url encoded", []string{"?key=" + url.QueryEscape("sk_live_abc123/xyz+")}, "?key=***API_KEY***"},
+		{"split across writes", []string{"pass: hun", "ter", "22 done"}, "pass: ***PASSWORD*** done"},
+		{"partial never completed", []string{"hunt", "ing season"}, "hunting season"},
+		{"partial at end of stream", []string{"ends with hunter2"}, "ends with hunter2"},
+		{"short values kept", []string{"listening on 80\n"}, "listening on 80\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactAll(t, secrets, tt.chunks...); got != tt.want {
+				t.Errorf("redacted = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorByteByByte(t *testing.T) {
+	input := "a hunter22 b sk_live_abc123/xyz+ c\n"
+	chunks := strings.Split(input, "")
+	got := redactAll(t, map[string]string{"PASSWORD": "hunter22", "API_KEY": "sk_live_abc123/xyz+"}, chunks...)
+	if want := "a ***PASSWORD*** b ***API_KEY*** c\n"; got != want {
+		t.Errorf("redacted = %q, want %q", got, want)
+	}
+}
+
+func TestRedactorPassesLinesThrough(t *testing.T) {
+	var out bytes.Buffer
+	r := service.NewRedactor(&out, map[string]string{"PASSWORD": "hunter22"})
+
+	r.Write([]byte("first line\n"))
+	if out.String() != "first line\n" || r.Buffered() != 0 {
+		t.Errorf("line was held back: out=%q buffered=%d", out.String(), r.Buffered())
+	}
+
+	r.Write([]byte(strings.Repeat("x", 1<<16) + "hunt"))
+	if r.Buffered() != len("hunt") {
+		t.Errorf("Buffered() = %d, want %d", r.Buffered(), len("hunt"))
+	}
+}
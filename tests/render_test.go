@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"envy/internal/domain"
+	"envy/internal/service"
+)
+
+func TestParseTemplate(t *testing.T) {
+	app := projectWithValues("myapp", "prod", map[string]string{
+		"HOST":     "db.internal",
+		"URL":      "postgres://${HOST}/app",
+		"PASSWORD": `p"ss`,
+		"EMPTY":    "",
+	})
+	shared := projectWithValues("shared", "prod", map[string]string{"DSN": "https://sentry.io/1"})
+	resolver := service.NewResolver([]domain.Project{app, shared})
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr string
+	}{
+		{"secret", `{{ secret "HOST" }}`, "db.internal", ""},
+		{"references expanded", `{{ secret "URL" }}`, "postgres://db.internal/app", ""},
+		{"other project", `{{ secret "shared/prod/DSN" }}`, "https://sentry.io/1", ""},
+		{"json", `{{ secret "PASSWORD" | json }}`, `"p\"ss"`, ""},
+		{"b64enc", `{{ secret "HOST" | b64enc }}`, "ZGIuaW50ZXJuYWw=", ""},
+		{"required passes", `{{ secret "HOST" | required "need host" }}`, "db.internal", ""},
+		{"required fails", `{{ secret "EMPTY" | required "EMPTY is needed" }}`, "", "EMPTY is needed"},
+		{"missing secret", `{{ secret "NOPE" }}`, "", "not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := service.ParseTemplate("test", tt.text, resolver, app)
+			if err != nil {
+				t.Fatalf("ParseTemplate() error: %v", err)
+			}
+
+			var out strings.Builder
+			err = tmpl.Execute(&out, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Execute() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() error: %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("Execute() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+
+	tmpl, _ := service.ParseTemplate("test", `{{ secret "NOPE" }}`, resolver, app)
+	if err := tmpl.Execute(&strings.Builder{}, nil); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("missing secret error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestTemplateSecrets(t *testing.T) {
+	text := `{{ secret "A" }}
+{{ if .Debug }}{{ secret "B" | json }}{{ else }}{{ secret "C" }}{{ end }}
+{{ range .Items }}{{ (secret "D") | b64enc }}{{ end }}
+{{ "E" | secret }}
+{{ secret "A" }}`
+
+	tmpl, err := service.ParseTemplate("test", text, service.NewResolver(nil), domain.Project{})
+	if err != nil {
+		t.Fatalf("ParseTemplate() error: %v", err)
+	}
+
+	got := service.TemplateSecrets(tmpl)
+	want := []string{"A", "B", "C", "D", "E"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TemplateSecrets() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"envy/internal/config"
+	"envy/internal/domain"
+	"envy/internal/service"
+)
+
+func TestResolveProject(t *testing.T) {
+	projects := []domain.Project{
+		createTestProject("myapp", "prod", "KEY"),
+		createTestProject("myapp", "dev", "KEY"),
+		createTestProject("other", "stage", "KEY"),
+	}
+
+	tests := []struct {
+		name       string
+		project    string
+		env        string
+		defaultEnv string
+		wantEnv    string
+		wantErr    bool
+	}{
+		{"explicit env", "myapp", "dev", "", "dev", false},
+		{"case insensitive", "MyApp", "prod", "", "prod", false},
+		{"single environment", "other", "", "", "stage", false},
+		{"ambiguous without default", "myapp", "", "", "", true},
+		{"default breaks tie", "myapp", "", "dev", "dev", false},
+		{"default not a candidate", "myapp", "", "stage", "", true},
+		{"explicit env wins over default", "myapp", "prod", "dev", "prod", false},
+		{"missing env", "other", "prod", "", "", true},
+		{"missing project", "nope", "", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := service.ResolveProject(projects, tt.project, tt.env, tt.defaultEnv)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveProject() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.Environment != tt.wantEnv {
+				t.Errorf("ResolveProject() environment = %q, want %q", got.Environment, tt.wantEnv)
+			}
+		})
+	}
+}
+
+func TestResolveProjectErrors(t *testing.T) {
+	projects := []domain.Project{
+		createTestProject("myapp", "prod"),
+		createTestProject("myapp", "dev"),
+	}
+
+	_, err := service.ResolveProject(projects, "myapp", "", "")
+	var ambiguous *service.AmbiguousProjectError
+	if !errors.As(err, &ambiguous) {
+		t.Fatalf("ResolveProject() error = %v, want AmbiguousProjectError", err)
+	}
+	if len(ambiguous.Candidates) != 2 {
+		t.Errorf("AmbiguousProjectError lists %d candidates, want 2", len(ambiguous.Candidates))
+	}
+
+	_, err = service.ResolveProject(projects, "missing", "", "")
+	if !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("ResolveProject() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestResolveProjectOrderIndependent(t *testing.T) {
+	projects := []domain.Project{
+		createTestProject("myapp", "dev"),
+		createTestProject("myapp", "prod"),
+	}
+	vault := service.NewVaultService(projects, nil)
+
+	// DeleteProject swaps the last project into the removed slot
+	if err := vault.CreateProject(createTestProject("zzz", "dev")); err != nil {
+		t.Fatalf("CreateProject() error: %v", err)
+	}
+	if err := vault.DeleteProject("myapp", "dev"); err != nil {
+		t.Fatalf("DeleteProject() error: %v", err)
+	}
+
+	got, err := service.ResolveProject(vault.GetProjects(), "myapp", "", "")
+	if err != nil {
+		t.Fatalf("ResolveProject() error: %v", err)
+	}
+	if got.Environment != "prod" {
+		t.Errorf("ResolveProject() environment = %q, want prod", got.Environment)
+	}
+}
+
+// writeConfig writes config.lua where envy looks for it in home
+func writeConfig(t *testing.T, home, lua string) {
+	t.Helper()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	path := config.GetDefaultConfigPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(lua), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefaultEnvironmentConfig(t *testing.T) {
+	home := testVault(t, "password123", []domain.Project{
+		createTestProject("myapp", "dev", "API_KEY"),
+		createTestProject("myapp", "prod", "API_KEY"),
+	})
+	password := []string{"ENVY_MASTER_PASSWORD=password123"}
+
+	writeConfig(t, home, `defaults = { environment = "production" }`)
+	res := runEnvy(t, home, home, password, "ci", "export", "myapp", "--provider", "generic", "-o", "out.sh")
+	if res.code != 2 || !strings.Contains(res.stderr, "config defaults.environment") {
+		t.Errorf("invalid default: exit %d, stderr %q", res.code, res.stderr)
+	}
+
+	writeConfig(t, home, `defaults = { environment = "prod" }`)
+	res = runEnvy(t, home, home, password, "ci", "export", "myapp", "--provider", "generic", "-o", "out.sh")
+	if res.code != 0 {
+		t.Fatalf("valid default: exit %d, stderr %q", res.code, res.stderr)
+	}
+	data, err := os.ReadFile(filepath.Join(home, "out.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "(prod)") {
+		t.Errorf("default environment not used:\n%s", data)
+	}
+}
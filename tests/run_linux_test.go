@@ -0,0 +1,61 @@
+//go:build linux
+
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"envy/internal/domain"
+)
+
+func TestRunExitCodes(t *testing.T) {
+	home := testVault(t, "password123", []domain.Project{createTestProject("myapp", "dev", "API_KEY")})
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "not-executable"), []byte("#!/bin/sh\nexit 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		command []string
+		want    int
+	}{
+		{"success", []string{"sh", "-c", `test "$API_KEY" = secret-API_KEY`}, 0},
+		{"exit status", []string{"sh", "-c", "exit 3"}, 3},
+		{"killed by SIGTERM", []string{"sh", "-c", "kill -TERM $$"}, 143},
+		{"killed by SIGKILL", []string{"sh", "-c", "kill -KILL $$"}, 137},
+		{"not found", []string{"envy-no-such-command"}, 127},
+		{"missing path", []string{"./missing"}, 127},
+		{"not executable", []string{"./not-executable"}, 126},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"run", "myapp:dev", "--"}, tt.command...)
+			res := runEnvyWithPassword(t, home, dir, nil, "password123", args...)
+			if res.code != tt.want {
+				t.Errorf("exit %d, want %d; stderr %q", res.code, tt.want, res.stderr)
+			}
+		})
+	}
+}
+
+func TestRunForwardsSignals(t *testing.T) {
+	home := testVault(t, "password123", []domain.Project{createTestProject("myapp", "dev", "API_KEY")})
+
+	// The command signals envy, its parent, and exits with 42 once the
+	// signal comes back; without forwarding it gives up after 5 seconds
+	for _, sig := range []string{"TERM", "HUP", "USR1"} {
+		t.Run(sig, func(t *testing.T) {
+			script := strings.ReplaceAll(`trap "exit 42" SIG; kill -SIG $PPID; i=0
+while [ $i -lt 50 ]; do sleep 0.1; i=$((i+1)); done; exit 1`, "SIG", sig)
+			res := runEnvyWithPassword(t, home, home, nil, "password123", "run", "myapp:dev", "--", "sh", "-c", script)
+			if res.code != 42 {
+				t.Errorf("SIG%s: exit %d, want 42 from the command's trap; stderr %q", sig, res.code, res.stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"envy/internal/storage"
+)
+
+func TestSecretDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("runtime directory is the shared temp directory on Windows")
+	}
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	dir, err := storage.CreateSecretDir()
+	if err != nil {
+		t.Fatalf("CreateSecretDir() error: %v", err)
+	}
+
+	path, err := dir.WriteFile("GOOGLE_CREDS", `{"type":"service_account"}`)
+	if err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("secret file missing: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("secret file mode = %o, want 600", info.Mode().Perm())
+	}
+	if data, _ := os.ReadFile(path); string(data) != `{"type":"service_account"}` {
+		t.Errorf("secret file holds %q", data)
+	}
+
+	if _, err := dir.WriteFile("GOOGLE_CREDS", "again"); err == nil {
+		t.Error("WriteFile() should not overwrite an existing file")
+	}
+
+	if err := dir.Remove(); err != nil {
+		t.Fatalf("Remove() error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(path)); !os.IsNotExist(err) {
+		t.Errorf("secrets directory still exists after Remove(): %v", err)
+	}
+}
+
+func TestSweepSecretDirs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("runtime directory is the shared temp directory on Windows")
+	}
+	runtimeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+
+	live, err := storage.CreateSecretDir()
+	if err != nil {
+		t.Fatalf("CreateSecretDir() error: %v", err)
+	}
+	defer live.Remove()
+	livePath, _ := live.WriteFile("KEY", "value")
+
+	// Left behind by a process that no longer exists
+	stale := filepath.Join(runtimeDir, "envy", "run-stale")
+	if err := os.MkdirAll(stale, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(stale, ".owner"), []byte("2147483646"), 0o600)
+	os.WriteFile(filepath.Join(stale, "KEY"), []byte("value"), 0o600)
+
+	storage.SweepSecretDirs()
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale directory was not swept: %v", err)
+	}
+	if _, err := os.Stat(livePath); err != nil {
+		t.Errorf("directory of a running process was swept: %v", err)
+	}
+}
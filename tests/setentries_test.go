@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"envy/internal/auth"
+	"envy/internal/service"
+)
+
+// setSources serves stdin, prompts and files from memory and counts how
+// often stdin is read
+func setSources(stdin string, prompts, files map[string]string, stdinReads *int) service.SetSources {
+	return service.SetSources{
+		Stdin: func() ([]byte, error) {
+			*stdinReads++
+			return []byte(stdin), nil
+		},
+		Prompt: func(key string) (string, error) {
+			value, ok := prompts[key]
+			if !ok {
+				return "", errors.New("unexpected prompt for " + key)
+			}
+			return value, nil
+		},
+		ReadFile: func(path string) ([]byte, error) {
+			data, ok := files[path]
+			if !ok {
+				return nil, os.ErrNotExist
+			}
+			return []byte(data), nil
+		},
+	}
+}
+
+func TestCollectSetEntries(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		useStdin  bool
+		fromFiles []string
+		want      []service.SetEntry
+	}{
+		{
+			name: "bulk KEY=VALUE",
+			args: []string{"API_KEY=sk=1", " DB_HOST =localhost", "DB_PORT=5432"},
+			want: []service.SetEntry{{Key: "API_KEY", Value: "sk=1"}, {Key: "DB_HOST", Value: "localhost"}, {Key: "DB_PORT", Value: "5432"}},
+		},
+		{
+			name: "KEY - reads stdin",
+			args: []string{"TOKEN", "-", "DEBUG=1"},
+			want: []service.SetEntry{{Key: "TOKEN", Value: "from-stdin"}, {Key: "DEBUG", Value: "1"}},
+		},
+		{
+			name:     "--stdin reads the bare key",
+			args:     []string{"DEBUG=1", "TOKEN"},
+			useStdin: true,
+			want:     []service.SetEntry{{Key: "DEBUG", Value: "1"}, {Key: "TOKEN", Value: "from-stdin"}},
+		},
+		{
+			name: "bare key is prompted for",
+			args: []string{"PASSWORD"},
+			want: []service.SetEntry{{Key: "PASSWORD", Value: "hunter22"}},
+		},
+		{
+			name:      "--from-file keeps the file as is",
+			args:      []string{"A=1"},
+			fromFiles: []string{"TLS_CERT=cert.pem"},
+			want:      []service.SetEntry{{Key: "A", Value: "1"}, {Key: "TLS_CERT", Value: "-----BEGIN-----\nabc\n"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reads := 0
+			src := setSources("from-stdin\r\n", map[string]string{"PASSWORD": "hunter22"},
+				map[string]string{"cert.pem": "-----BEGIN-----\nabc\n"}, &reads)
+
+			got, err := service.CollectSetEntries(tt.args, tt.useStdin, tt.fromFiles, src)
+			if err != nil {
+				t.Fatalf("CollectSetEntries: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("entries = %v, want %v", got, tt.want)
+			}
+			if reads > 1 {
+				t.Errorf("stdin read %d times", reads)
+			}
+		})
+	}
+}
+
+func TestCollectSetEntriesErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		useStdin  bool
+		fromFiles []string
+		wantKey   string
+		wantErr   string
+	}{
+		{"nothing given", nil, false, nil, "", "no secrets given"},
+		{"lone dash", []string{"-"}, false, nil, "", "'-' must follow a key name"},
+		{"two stdin keys", []string{"A", "-", "B", "-"}, false, nil, "B", "only one key"},
+		{"--stdin and dash", []string{"A", "-", "B"}, true, nil, "B", "only one key"},
+		{"--stdin without bare key", []string{"A=1"}, true, nil, "", "--stdin requires a bare KEY"},
+		{"duplicate key", []string{"A=1", "A=2"}, false, nil, "A", "given more than once"},
+		{"duplicate across sources", []string{"A=1"}, false, []string{"A=cert.pem"}, "A", "given more than once"},
+		{"empty value", []string{"A="}, false, nil, "A", "cannot be empty"},
+		{"empty key", []string{"=value"}, false, nil, "", "key name cannot be empty"},
+		{"invalid key", []string{strings.Repeat("K", 257) + "=1"}, false, nil, strings.Repeat("K", 257), "invalid key name"},
+		{"bad --from-file spec", nil, false, []string{"cert.pem"}, "", "Expected: KEY=path"},
+		{"missing file", nil, false, []string{"CERT=missing.pem"}, "CERT", "failed to read value for 'CERT'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reads := 0
+			src := setSources("v", nil, map[string]string{"cert.pem": "x"}, &reads)
+
+			_, err := service.CollectSetEntries(tt.args, tt.useStdin, tt.fromFiles, src)
+			var inputErr *service.SetInputError
+			if !errors.As(err, &inputErr) {
+				t.Fatalf("error = %v, want a SetInputError", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.wantErr)
+			}
+			if inputErr.Key != tt.wantKey {
+				t.Errorf("Key = %q, want %q", inputErr.Key, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestCollectSetEntriesSourceErrors(t *testing.T) {
+	failing := service.SetSources{
+		Stdin:  func() ([]byte, error) { return nil, errors.New("broken pipe") },
+		Prompt: func(string) (string, error) { return "", errors.New("no terminal") },
+	}
+
+	for _, args := range [][]string{{"A", "-"}, {"A"}} {
+		_, err := service.CollectSetEntries(args, false, nil, failing)
+		var inputErr *service.SetInputError
+		if err == nil || errors.As(err, &inputErr) {
+			t.Errorf("%v: error = %v, want the source's error", args, err)
+		}
+	}
+}
+
+func TestPromptInputFallsBackToTerminal(t *testing.T) {
+	stdinRead, stdinWrite, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdinRead.Close()
+	defer stdinWrite.Close()
+
+	// A piped stdin is left alone and prompts read the terminal instead
+	tty := filepath.Join(t.TempDir(), "tty")
+	if err := os.WriteFile(tty, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	input, cleanup := auth.PromptInput(stdinRead, tty)
+	if input.Name() != tty {
+		t.Errorf("input = %s, want %s", input.Name(), tty)
+	}
+	cleanup()
+	if _, err := input.Stat(); err == nil {
+		t.Error("cleanup left the terminal open")
+	}
+
+	// Without a terminal, stdin is all there is
+	input, cleanup = auth.PromptInput(stdinRead, filepath.Join(t.TempDir(), "missing"))
+	defer cleanup()
+	if input != stdinRead {
+		t.Errorf("input = %s, want stdin", input.Name())
+	}
+}
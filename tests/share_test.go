@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"envy/internal/service"
+)
+
+func newIdentity(t *testing.T, name string) service.Identity {
+	t.Helper()
+	identity, _, err := service.NewIdentity(name)
+	if err != nil {
+		t.Fatalf("NewIdentity: %v", err)
+	}
+	return identity
+}
+
+func TestIdentityFromSeed(t *testing.T) {
+	identity, seed, err := service.NewIdentity("alice")
+	if err != nil {
+		t.Fatalf("NewIdentity: %v", err)
+	}
+	again, err := service.IdentityFromSeed("alice", seed)
+	if err != nil {
+		t.Fatalf("IdentityFromSeed: %v", err)
+	}
+	if again.PublicKey().String() != identity.PublicKey().String() {
+		t.Error("the same seed gave another key")
+	}
+
+	parsed, err := service.ParsePublicKey(identity.PublicKey().String() + "\n")
+	if err != nil {
+		t.Fatalf("ParsePublicKey: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, identity.PublicKey()) {
+		t.Error("public key doesn't survive a round trip")
+	}
+
+	for _, bad := range []string{"", "age1abc", service.PublicKeyPrefix + "abc"} {
+		if _, err := service.ParsePublicKey(bad); err == nil {
+			t.Errorf("ParsePublicKey(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	alice, bob := newIdentity(t, "alice"), newIdentity(t, "bob")
+	shared := service.SharedProject{
+		Project:     "myapp",
+		Environment: "prod",
+		Keys:        []service.ImportedKey{{Key: "API_KEY", Value: "sk_live_1", Description: "Stripe"}},
+	}
+	now := time.Now()
+
+	bundle, err := service.SealBundle(alice, bob.PublicKey(), shared, now, time.Hour)
+	if err != nil {
+		t.Fatalf("SealBundle: %v", err)
+	}
+	if bundle.Sender.Name != "alice" || bundle.Sender.PublicKey != alice.PublicKey().String() {
+		t.Errorf("sender = %+v", bundle.Sender)
+	}
+	if bytes.Contains(bundle.Ciphertext, []byte("sk_live_1")) {
+		t.Error("value stored in plain text")
+	}
+
+	opened, err := service.OpenBundle(bundle, bob, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("OpenBundle: %v", err)
+	}
+	if !reflect.DeepEqual(opened, shared) {
+		t.Errorf("opened = %+v, want %+v", opened, shared)
+	}
+
+	if _, err := service.OpenBundle(bundle, newIdentity(t, "eve"), now); err == nil {
+		t.Error("another identity opened the bundle")
+	}
+	if _, err := service.OpenBundle(bundle, bob, now.Add(2*time.Hour)); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("expired bundle: err = %v", err)
+	}
+}
+
+func TestBundleRejectsTampering(t *testing.T) {
+	alice, bob, eve := newIdentity(t, "alice"), newIdentity(t, "bob"), newIdentity(t, "eve")
+	shared := service.SharedProject{Project: "myapp", Environment: "dev", Keys: []service.ImportedKey{{Key: "A", Value: "1"}}}
+	now := time.Now()
+
+	bundle, err := service.SealBundle(alice, bob.PublicKey(), shared, now, time.Hour)
+	if err != nil {
+		t.Fatalf("SealBundle: %v", err)
+	}
+
+	extended := bundle
+	extended.ExpiresAt = extended.ExpiresAt.Add(24 * time.Hour)
+
+	impersonated := bundle
+	impersonated.Sender = service.BundleSender{Name: "eve", PublicKey: eve.PublicKey().String()}
+
+	flipped := bundle
+	flipped.Ciphertext = append([]byte{}, bundle.Ciphertext...)
+	flipped.Ciphertext[0] ^= 1
+
+	for name, b := range map[string]service.Bundle{"expiry": extended, "sender": impersonated, "ciphertext": flipped} {
+		if _, err := service.OpenBundle(b, bob, now); err == nil {
+			t.Errorf("bundle with changed %s was accepted", name)
+		}
+	}
+}
@@ -272,3 +272,42 @@ func TestGetEncryptionKey(t *testing.T) {
 		t.Errorf("GetEncryptionKey() = %v, want %v", key, expectedKey)
 	}
 }
+
+func TestRollbackKey(t *testing.T) {
+	vault := service.NewVaultService([]domain.Project{
+		createTestProject("project", "dev", "API_KEY"),
+	}, nil)
+
+	original := "secret-API_KEY"
+	if err := vault.UpdateKey("project", "dev", "API_KEY", "v2"); err != nil {
+		t.Fatalf("UpdateKey() error: %v", err)
+	}
+
+	if err := vault.RollbackKey("project", "dev", "API_KEY", 1); err != nil {
+		t.Fatalf("RollbackKey() error: %v", err)
+	}
+
+	proj, _ := vault.GetProject("project", "dev")
+	apiKey := proj.Keys[0]
+	if apiKey.Current.Value != original {
+		t.Errorf("RollbackKey() current = %q, want %q", apiKey.Current.Value, original)
+	}
+
+	// The replaced value must be kept so the rollback can be undone
+	if len(apiKey.History) != 2 || apiKey.History[1].Value != "v2" {
+		t.Errorf("RollbackKey() history = %v, want [%s v2]", apiKey.History, original)
+	}
+
+	// Out of range versions
+	if err := vault.RollbackKey("project", "dev", "API_KEY", 0); err == nil {
+		t.Error("RollbackKey() should reject version 0")
+	}
+	if err := vault.RollbackKey("project", "dev", "API_KEY", 3); err == nil {
+		t.Error("RollbackKey() should reject a version beyond history")
+	}
+
+	// Non-existent key
+	if err := vault.RollbackKey("project", "dev", "NONEXISTENT", 1); err == nil {
+		t.Error("RollbackKey() should return error for non-existent key")
+	}
+}
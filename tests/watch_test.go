@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"envy/internal/service"
+)
+
+func TestDecideReload(t *testing.T) {
+	current := map[string]string{"API_KEY": "abc", "DB_URL": "postgres://db"}
+
+	tests := []struct {
+		name string
+		next map[string]string
+		err  error
+		want service.ReloadDecision
+	}{
+		{"same values", map[string]string{"DB_URL": "postgres://db", "API_KEY": "abc"}, nil, service.KeepUnchanged},
+		{"value changed", map[string]string{"API_KEY": "xyz", "DB_URL": "postgres://db"}, nil, service.Restart},
+		{"key added", map[string]string{"API_KEY": "abc", "DB_URL": "postgres://db", "NEW": "1"}, nil, service.Restart},
+		{"key removed", map[string]string{"API_KEY": "abc"}, nil, service.Restart},
+		{"value emptied", map[string]string{"API_KEY": "", "DB_URL": "postgres://db"}, nil, service.Restart},
+		{"vault unreadable", nil, errors.New("failed to decrypt vault"), service.KeepFailed},
+		{"error with changed values", map[string]string{"API_KEY": "xyz"}, errors.New("reference cycle"), service.KeepFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := service.DecideReload(current, tt.next, tt.err); got != tt.want {
+				t.Errorf("DecideReload() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeProcess records the signals sent to it. It exits when it receives
+// exitOn, or when it's killed.
+type fakeProcess struct {
+	exitOn    os.Signal
+	signalErr error
+	done      chan error
+
+	mu      sync.Mutex
+	signals []os.Signal
+	killed  bool
+}
+
+func newFakeProcess(exitOn os.Signal) *fakeProcess {
+	return &fakeProcess{exitOn: exitOn, done: make(chan error, 1)}
+}
+
+func (p *fakeProcess) Signal(sig os.Signal) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.signalErr != nil {
+		return p.signalErr
+	}
+	p.signals = append(p.signals, sig)
+	if sig == p.exitOn {
+		p.done <- nil
+	}
+	return nil
+}
+
+func (p *fakeProcess) Kill() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.killed {
+		p.killed = true
+		p.done <- errors.New("signal: killed")
+	}
+	return nil
+}
+
+func TestStopProcessExitsOnSignal(t *testing.T) {
+	process := newFakeProcess(syscall.SIGHUP)
+
+	if service.StopProcess(process, process.done, syscall.SIGHUP, time.Minute) {
+		t.Error("StopProcess() killed a process that exited on its signal")
+	}
+	if process.killed {
+		t.Error("Kill() was called")
+	}
+	if len(process.signals) != 1 || process.signals[0] != syscall.SIGHUP {
+		t.Errorf("signals = %v, want [SIGHUP]", process.signals)
+	}
+}
+
+func TestStopProcessKillsAfterGrace(t *testing.T) {
+	// Ignores the restart signal, like a command without a handler for it
+	process := newFakeProcess(nil)
+	grace := 50 * time.Millisecond
+
+	start := time.Now()
+	if !service.StopProcess(process, process.done, syscall.SIGTERM, grace) {
+		t.Error("StopProcess() = false, want the process killed")
+	}
+	if elapsed := time.Since(start); elapsed < grace {
+		t.Errorf("killed after %s, before the %s grace period", elapsed, grace)
+	}
+	if !process.killed {
+		t.Error("Kill() wasn't called")
+	}
+	if len(process.signals) != 1 || process.signals[0] != syscall.SIGTERM {
+		t.Errorf("signals = %v, want SIGTERM before the kill", process.signals)
+	}
+}
+
+func TestStopProcessKillsWhenSignalFails(t *testing.T) {
+	process := newFakeProcess(nil)
+	process.signalErr = errors.New("not supported by windows")
+
+	start := time.Now()
+	service.StopProcess(process, process.done, syscall.SIGTERM, time.Minute)
+	if !process.killed {
+		t.Error("Kill() wasn't called")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("waited %s for the grace period after the signal failed", elapsed)
+	}
+}